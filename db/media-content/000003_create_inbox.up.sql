@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS media_content.inbox (
+    message_id VARCHAR(64) NOT NULL,
+    command_type VARCHAR(100) NOT NULL,
+    correlation_id UUID NOT NULL,
+    reply_event VARCHAR(100),
+    reply_payload JSONB,
+    processed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, command_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inbox_correlation_id ON media_content.inbox (correlation_id);
+CREATE INDEX IF NOT EXISTS idx_inbox_processed_at ON media_content.inbox (processed_at);
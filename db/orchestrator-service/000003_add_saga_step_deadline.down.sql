@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS orchestrator_service.idx_saga_steps_pending_deadline;
+ALTER TABLE orchestrator_service.saga_steps DROP COLUMN IF EXISTS deadline_at;
@@ -0,0 +1,7 @@
+ALTER TABLE orchestrator_service.saga_steps
+    ADD COLUMN IF NOT EXISTS deadline_at TIMESTAMP;
+
+-- Partial index for the timeout scheduler, which only scans pending steps
+CREATE INDEX IF NOT EXISTS idx_saga_steps_pending_deadline
+    ON orchestrator_service.saga_steps(deadline_at)
+    WHERE status = 'PENDING' AND deadline_at IS NOT NULL;
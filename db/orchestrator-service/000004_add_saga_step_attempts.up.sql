@@ -0,0 +1,2 @@
+ALTER TABLE orchestrator_service.saga_steps
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
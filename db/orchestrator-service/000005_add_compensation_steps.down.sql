@@ -0,0 +1 @@
+ALTER TABLE orchestrator_service.saga_steps DROP COLUMN IF EXISTS is_compensation;
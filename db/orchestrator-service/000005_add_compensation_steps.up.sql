@@ -0,0 +1,2 @@
+ALTER TABLE orchestrator_service.saga_steps
+    ADD COLUMN IF NOT EXISTS is_compensation BOOLEAN NOT NULL DEFAULT FALSE;
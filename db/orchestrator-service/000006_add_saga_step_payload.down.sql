@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS orchestrator_service.idx_saga_state_state_created_at;
+ALTER TABLE orchestrator_service.saga_steps DROP COLUMN IF EXISTS payload;
@@ -0,0 +1,5 @@
+ALTER TABLE orchestrator_service.saga_steps
+    ADD COLUMN IF NOT EXISTS payload JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_saga_state_state_created_at
+    ON orchestrator_service.saga_state (state, created_at DESC);
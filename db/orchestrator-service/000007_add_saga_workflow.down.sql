@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS orchestrator_service.idx_saga_state_workflow;
+ALTER TABLE orchestrator_service.saga_state
+    DROP COLUMN IF EXISTS workflow_version,
+    DROP COLUMN IF EXISTS workflow;
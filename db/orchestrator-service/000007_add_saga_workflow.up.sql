@@ -0,0 +1,15 @@
+ALTER TABLE orchestrator_service.saga_state
+    ADD COLUMN IF NOT EXISTS workflow VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS workflow_version INTEGER;
+
+-- Sagas started before workflows were named all belong to user registration
+UPDATE orchestrator_service.saga_state
+SET workflow = 'register_user', workflow_version = 1
+WHERE workflow IS NULL;
+
+ALTER TABLE orchestrator_service.saga_state
+    ALTER COLUMN workflow SET NOT NULL,
+    ALTER COLUMN workflow_version SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_saga_state_workflow
+    ON orchestrator_service.saga_state (workflow, workflow_version);
@@ -0,0 +1 @@
+ALTER TABLE orchestrator_service.saga_state DROP COLUMN IF EXISTS version;
@@ -0,0 +1,2 @@
+ALTER TABLE orchestrator_service.saga_state
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
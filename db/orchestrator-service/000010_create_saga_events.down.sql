@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS record_saga_state_change ON orchestrator_service.saga_state;
+DROP FUNCTION IF EXISTS orchestrator_service.record_saga_state_change();
+DROP TABLE IF EXISTS orchestrator_service.saga_events;
+DROP FUNCTION IF EXISTS orchestrator_service.saga_events_append_only();
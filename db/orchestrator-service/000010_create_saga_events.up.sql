@@ -0,0 +1,50 @@
+-- Append-only history of everything a saga saw and did
+CREATE TABLE IF NOT EXISTS orchestrator_service.saga_events (
+    id BIGSERIAL PRIMARY KEY,
+    correlation_id UUID NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    type VARCHAR(100) NOT NULL,
+    message_id VARCHAR(64),
+    payload JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_saga_events_correlation_id
+    ON orchestrator_service.saga_events (correlation_id, id);
+
+-- Redelivered messages are recorded once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_saga_events_message
+    ON orchestrator_service.saga_events (correlation_id, kind, message_id)
+    WHERE message_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION orchestrator_service.saga_events_append_only()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'saga_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER saga_events_append_only
+    BEFORE UPDATE OR DELETE ON orchestrator_service.saga_events
+    FOR EACH ROW
+    EXECUTE FUNCTION orchestrator_service.saga_events_append_only();
+
+-- Record saga state transitions, including data changes
+CREATE OR REPLACE FUNCTION orchestrator_service.record_saga_state_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.state IS NOT DISTINCT FROM NEW.state AND OLD.data IS NOT DISTINCT FROM NEW.data THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO orchestrator_service.saga_events (correlation_id, kind, type, payload)
+    VALUES (NEW.correlation_id, 'STATE_CHANGED', NEW.state, NEW.data);
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER record_saga_state_change
+    AFTER INSERT OR UPDATE ON orchestrator_service.saga_state
+    FOR EACH ROW
+    EXECUTE FUNCTION orchestrator_service.record_saga_state_change();
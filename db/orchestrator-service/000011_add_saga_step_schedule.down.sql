@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS orchestrator_service.idx_saga_steps_scheduled;
+ALTER TABLE orchestrator_service.saga_steps
+    DROP COLUMN IF EXISTS outbox_id,
+    DROP COLUMN IF EXISTS scheduled_at;
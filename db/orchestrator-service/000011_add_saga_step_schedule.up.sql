@@ -0,0 +1,8 @@
+ALTER TABLE orchestrator_service.saga_steps
+    ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS outbox_id UUID;
+
+-- Scheduled steps are looked up per saga when it completes or compensates
+CREATE INDEX IF NOT EXISTS idx_saga_steps_scheduled
+    ON orchestrator_service.saga_steps (saga_state_id)
+    WHERE outbox_id IS NOT NULL;
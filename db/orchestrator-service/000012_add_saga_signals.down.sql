@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS orchestrator_service.idx_saga_state_awaiting_signals;
+ALTER TABLE orchestrator_service.saga_state DROP COLUMN IF EXISTS signals;
@@ -0,0 +1,7 @@
+ALTER TABLE orchestrator_service.saga_state
+    ADD COLUMN IF NOT EXISTS signals JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- The timeout scheduler only scans sagas that wait for a signal
+CREATE INDEX IF NOT EXISTS idx_saga_state_awaiting_signals
+    ON orchestrator_service.saga_state (state)
+    WHERE signals <> '{}'::jsonb;
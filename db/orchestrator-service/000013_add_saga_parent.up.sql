@@ -0,0 +1,7 @@
+ALTER TABLE orchestrator_service.saga_state
+    ADD COLUMN IF NOT EXISTS parent_correlation_id UUID
+        REFERENCES orchestrator_service.saga_state(correlation_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_saga_state_parent_correlation_id
+    ON orchestrator_service.saga_state (parent_correlation_id)
+    WHERE parent_correlation_id IS NOT NULL;
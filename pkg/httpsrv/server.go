@@ -14,7 +14,7 @@ func Module() fx.Option {
 		fx.Provide(
 			NewHTTPServer,
 		),
-		fx.Invoke(runServer),
+		fx.Invoke(Serve),
 	)
 }
 
@@ -29,7 +29,9 @@ func NewHTTPServer(cfg *Config, eng *gin.Engine) *http.Server {
 	}
 }
 
-func runServer(lc fx.Lifecycle, srv *http.Server) {
+// Serve runs srv for the lifetime of the app. Services call it directly for
+// listeners other than the main one.
+func Serve(lc fx.Lifecycle, srv *http.Server) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
 			go func() {
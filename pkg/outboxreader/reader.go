@@ -0,0 +1,83 @@
+package outboxreader
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/oagudo/outbox"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+
+	"soa-video-streaming/pkg/postgres"
+)
+
+// Run starts an outbox reader that relays the outbox table of pool through
+// publisher for the lifetime of lc.
+func Run(lc fx.Lifecycle, pool *postgres.Client, publisher outbox.MessagePublisher) {
+	var db *sql.DB
+	var reader *outbox.Reader
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			db = stdlib.OpenDBFromPool(pool.Pool)
+
+			dbCtx := outbox.NewDBContext(db, outbox.SQLDialectPostgres)
+
+			reader = outbox.NewReader(
+				dbCtx,
+				publisher,
+				outbox.WithInterval(15*time.Second),
+				outbox.WithReadBatchSize(10),
+			)
+
+			reader.Start()
+			go logErrors(reader)
+
+			logrus.Info("Outbox reader started")
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			var errs []error
+
+			if reader != nil {
+				errs = append(errs, reader.Stop(ctx))
+			}
+
+			if db != nil {
+				errs = append(errs, db.Close())
+			}
+
+			return errors.Join(errs...)
+		},
+	})
+}
+
+func logErrors(reader *outbox.Reader) {
+	for err := range reader.Errors() {
+		switch e := err.(type) {
+		case *outbox.PublishError:
+			logrus.Printf("Failed to publish message | ID: %s | Error: %v",
+				e.Message.ID, e.Err)
+
+		case *outbox.UpdateError:
+			logrus.Printf("Failed to update message | ID: %s | Error: %v",
+				e.Message.ID, e.Err)
+
+		case *outbox.DeleteError:
+			logrus.Printf("Batch message deletion failed | Count: %d | Error: %v",
+				len(e.Messages), e.Err)
+			for _, msg := range e.Messages {
+				logrus.Printf("Failed to delete message | ID: %s", msg.ID)
+			}
+
+		case *outbox.ReadError:
+			logrus.Printf("Failed to read outbox messages | Error: %v", e.Err)
+
+		default:
+			logrus.Printf("Unexpected error occurred | Error: %v", e)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package rabbitmq
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wagslane/go-rabbitmq"
+)
+
+// ContextHandler handles a delivery under a context that carries the handler
+// timeout and is cancelled when the processor stops.
+type ContextHandler func(ctx context.Context, d rabbitmq.Delivery) rabbitmq.Action
+
+// Processor runs consumer handlers with per-message contexts and drains
+// in-flight handlers on shutdown.
+type Processor struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	timeout  time.Duration
+	inFlight sync.WaitGroup
+	draining atomic.Bool
+}
+
+func (c *Client) NewProcessor() *Processor {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Processor{
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: c.cfg.HandlerTimeout,
+	}
+}
+
+// Handler adapts h to a consumer handler. Deliveries that arrive while the
+// processor drains are requeued for another consumer.
+func (p *Processor) Handler(h ContextHandler) rabbitmq.Handler {
+	return func(d rabbitmq.Delivery) rabbitmq.Action {
+		if p.draining.Load() {
+			return rabbitmq.NackRequeue
+		}
+
+		p.inFlight.Add(1)
+		defer p.inFlight.Done()
+
+		ctx, cancel := p.ctx, context.CancelFunc(func() {})
+		if p.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		}
+		defer cancel()
+
+		return h(ctx, d)
+	}
+}
+
+// Drain stops accepting deliveries and waits for in-flight handlers. When ctx
+// ends first, their contexts are cancelled and Drain waits for them to
+// return.
+func (p *Processor) Drain(ctx context.Context) {
+	p.draining.Store(true)
+
+	done := make(chan struct{})
+	go func() {
+		p.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logrus.Warn("Shutdown deadline reached, cancelling in-flight handlers")
+		p.cancel()
+		<-done
+	}
+
+	p.cancel()
+}
+
+// Concurrency returns the configured number of handler goroutines per
+// consumer.
+func (c *Client) Concurrency() int {
+	return max(c.cfg.Concurrency, 1)
+}
+
+// ConsumerOptions returns the prefetch setting of the config. Consumers that
+// do not dispatch deliveries themselves also get the configured concurrency.
+func (c *Client) ConsumerOptions(dispatched bool) []func(*rabbitmq.ConsumerOptions) {
+	var opts []func(*rabbitmq.ConsumerOptions)
+
+	if !dispatched && c.cfg.Concurrency > 0 {
+		opts = append(opts, rabbitmq.WithConsumerOptionsConcurrency(c.cfg.Concurrency))
+	}
+
+	if c.cfg.PrefetchCount > 0 {
+		opts = append(opts, rabbitmq.WithConsumerOptionsQOSPrefetch(c.cfg.PrefetchCount))
+	}
+
+	return opts
+}
@@ -19,6 +19,14 @@ func Module() fx.Option {
 type Config struct {
 	URL            string        `mapstructure:"url"`
 	ReconnectDelay time.Duration `mapstructure:"reconnect_delay"`
+	// ContentType selects the wire format of published saga messages
+	ContentType string `mapstructure:"content_type"`
+	// Concurrency is the number of handler goroutines per consumer and
+	// PrefetchCount the number of unacknowledged deliveries it may hold.
+	Concurrency   int `mapstructure:"concurrency"`
+	PrefetchCount int `mapstructure:"prefetch_count"`
+	// HandlerTimeout bounds the context of each handled delivery.
+	HandlerTimeout time.Duration `mapstructure:"handler_timeout"`
 }
 
 type Client struct {
@@ -41,6 +49,10 @@ func NewClient(cfg *Config) (*Client, error) {
 	}, nil
 }
 
+func (c *Client) ContentType() string {
+	return c.cfg.ContentType
+}
+
 func (c *Client) withRawChannel(fn func(ch *amqp.Channel) error) error {
 	conn, err := amqp.Dial(c.cfg.URL)
 	if err != nil {
@@ -89,6 +101,23 @@ func (c *Client) CreateQueue(name string, durable, autoDelete, exclusive bool) e
 	})
 }
 
+func (c *Client) CreateQueueWithArgs(name string, durable bool, args amqp.Table) error {
+	return c.withRawChannel(func(ch *amqp.Channel) error {
+		_, err := ch.QueueDeclare(
+			name,
+			durable,
+			false,
+			false,
+			false,
+			args,
+		)
+		if err != nil {
+			return fmt.Errorf("queue declare %q: %w", name, err)
+		}
+		return nil
+	})
+}
+
 func (c *Client) BindQueue(queue, exchange, routingKey string) error {
 	return c.withRawChannel(func(ch *amqp.Channel) error {
 		if err := ch.QueueBind(
@@ -3,144 +3,419 @@ package saga
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/oagudo/outbox"
 	"github.com/sirupsen/logrus"
-	gorabbit "github.com/wagslane/go-rabbitmq"
 	"go.uber.org/fx"
 )
 
+const DeadLetterQueue = "queue.saga.errors"
+
 type CommandHandler func(ctx context.Context, msg *Message) (any, error)
 
+// TxCommandHandler runs inside the actor's transaction, so its writes commit
+// atomically with the inbox record.
+type TxCommandHandler func(ctx context.Context, tx pgx.Tx, msg *Message) (any, error)
+
 type actorHandler struct {
-	handler      CommandHandler
+	handler      TxCommandHandler
 	successEvent string
+	failureEvent string
 	replyQueue   string
+	retry        *RetryPolicy
+}
+
+type outcome struct {
+	event   string
+	payload any
+	replied bool
+}
+
+type RegisterOption func(h *actorHandler)
+
+// WithRetryPolicy sets the default retry policy for a command. A policy sent
+// by the coordinator along with the command takes precedence.
+func WithRetryPolicy(policy RetryPolicy) RegisterOption {
+	return func(h *actorHandler) {
+		h.retry = &policy
+	}
+}
+
+type ActorOption func(a *Actor)
+
+func WithTransactionManager(tm TransactionManager) ActorOption {
+	return func(a *Actor) {
+		a.tm = tm
+	}
+}
+
+// WithInbox deduplicates commands by message ID and command type. Duplicates
+// are not handled again; the stored reply is re-sent instead.
+func WithInbox(inbox InboxRepository) ActorOption {
+	return func(a *Actor) {
+		a.inbox = inbox
+	}
+}
+
+// WithServiceName sets the source recorded on replies. It defaults to the
+// command queue.
+func WithServiceName(name string) ActorOption {
+	return func(a *Actor) {
+		a.source = name
+	}
+}
+
+// WithCodec sets the wire format of replies. It defaults to the codec of the
+// transport. Commands are decoded by their own content type either way.
+func WithCodec(codec Codec) ActorOption {
+	return func(a *Actor) {
+		a.codec = codec
+	}
+}
+
+// WithSchemas validates commands before their handler runs. An invalid
+// command fails with the invalid_payload business error.
+func WithSchemas(schemas *SchemaRegistry) ActorOption {
+	return func(a *Actor) {
+		a.schemas = schemas
+	}
+}
+
+// WithFailureEvent makes business failures (see Fail) reply with the given
+// event on the reply queue instead of dead-lettering the command.
+func WithFailureEvent(event string) RegisterOption {
+	return func(h *actorHandler) {
+		h.failureEvent = event
+	}
 }
 
 type Actor struct {
-	client     *gorabbit.Consumer
+	transport  Transport
+	queue      string
 	handlers   map[string]actorHandler
 	outboxRepo OutboxRepository
-	publisher  *gorabbit.Publisher
+	tm         TransactionManager
+	inbox      InboxRepository
+	source     string
+	schemas    *SchemaRegistry
+	codec      Codec
 }
 
-func NewActor(lc fx.Lifecycle, conn *gorabbit.Conn, outboxRepo OutboxRepository, queue string) *Actor {
-	publisher, err := gorabbit.NewPublisher(
-		conn,
-		gorabbit.WithPublisherOptionsLogger(logrus.StandardLogger()),
-	)
-	if err != nil {
-		logrus.WithError(err).Fatal("Failed to create actor publisher")
-	}
-
+func NewActor(lc fx.Lifecycle, transport Transport, outboxRepo OutboxRepository, queue string, opts ...ActorOption) *Actor {
 	actor := &Actor{
+		transport:  transport,
+		queue:      queue,
 		handlers:   make(map[string]actorHandler),
 		outboxRepo: outboxRepo,
-		publisher:  publisher,
+		source:     queue,
+	}
+
+	for _, opt := range opts {
+		opt(actor)
+	}
+
+	if actor.inbox != nil && actor.tm == nil {
+		logrus.Fatal("Saga actor inbox requires a transaction manager")
+	}
+
+	if actor.outboxRepo != nil && actor.tm == nil {
+		logrus.Fatal("Saga actor outbox requires a transaction manager")
 	}
 
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
-			consumer, err := gorabbit.NewConsumer(
-				conn,
-				queue,
-				gorabbit.WithConsumerOptionsLogger(logrus.StandardLogger()),
-				gorabbit.WithConsumerOptionsQueueDurable,
-				gorabbit.WithConsumerOptionsQueueArgs(map[string]any{
-					"x-dead-letter-exchange":    "",
-					"x-dead-letter-routing-key": "queue.saga.errors",
-				}),
-			)
+			// Failed commands are retried through delayed redelivery and end
+			// up in the dead-letter queue, where the coordinator compensates.
+			err := transport.Subscribe(queue, actor.handleMessage, WithDeadLetterQueue(DeadLetterQueue))
 			if err != nil {
-				return fmt.Errorf("create consumer: %w", err)
+				return fmt.Errorf("subscribe %s: %w", queue, err)
 			}
 
-			actor.client = consumer
-
-			go func() {
-				if err := consumer.Run(actor.handleMessage); err != nil {
-					logrus.WithError(err).Error("Actor consumer stopped")
-				}
-			}()
-
 			logrus.WithField("queue", queue).Info("Saga Actor started")
 			return nil
 		},
-		OnStop: func(ctx context.Context) error {
-			if actor.client != nil {
-				actor.client.Close()
-			}
-			if actor.publisher != nil {
-				actor.publisher.Close()
-			}
-			return nil
-		},
 	})
 
 	return actor
 }
 
-func (a *Actor) Register(cmdType string, handler CommandHandler, successEvent, replyQueue string) {
-	a.handlers[cmdType] = actorHandler{
+func (a *Actor) Register(cmdType string, handler CommandHandler, successEvent, replyQueue string, opts ...RegisterOption) {
+	txHandler := func(ctx context.Context, _ pgx.Tx, msg *Message) (any, error) {
+		return handler(ctx, msg)
+	}
+
+	a.register(cmdType, txHandler, successEvent, replyQueue, opts...)
+}
+
+func (a *Actor) RegisterTx(cmdType string, handler TxCommandHandler, successEvent, replyQueue string, opts ...RegisterOption) {
+	if a.tm == nil {
+		logrus.WithField("cmd", cmdType).Fatal("Transactional saga handler requires a transaction manager")
+	}
+
+	a.register(cmdType, handler, successEvent, replyQueue, opts...)
+}
+
+func (a *Actor) register(cmdType string, handler TxCommandHandler, successEvent, replyQueue string, opts ...RegisterOption) {
+	h := actorHandler{
 		handler:      handler,
 		successEvent: successEvent,
 		replyQueue:   replyQueue,
 	}
-}
 
-func (a *Actor) handleMessage(d gorabbit.Delivery) gorabbit.Action {
-	var msg Message
-	if err := json.Unmarshal(d.Body, &msg); err != nil {
-		return gorabbit.NackDiscard
+	for _, opt := range opts {
+		opt(&h)
 	}
 
+	a.handlers[cmdType] = h
+}
+
+func (a *Actor) handleMessage(ctx context.Context, msg *Message) Action {
 	handler, ok := a.handlers[msg.Type]
 	if !ok {
-		return gorabbit.NackDiscard
+		return Reject
+	}
+
+	msg.Attempt = max(msg.Attempt, 1)
+
+	ctx = ContextWithMessage(ctx, msg)
+
+	out, err := a.execute(ctx, msg, handler)
+	// Shutdown, not the handler timeout: another instance takes over
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		logrus.WithError(err).WithField("cmd", msg.Type).Warn("Command interrupted, requeueing")
+		return Requeue
 	}
 
-	result, err := handler.handler(context.Background(), &msg)
 	if err != nil {
-		logrus.WithError(err).WithField("cmd", msg.Type).Error("Command failed, sending to DLQ")
-		return gorabbit.NackDiscard
+		policy := handler.retry
+		if msg.Retry != nil {
+			policy = msg.Retry
+		}
+
+		log := logrus.WithError(err).WithFields(logrus.Fields{
+			"cmd":     msg.Type,
+			"attempt": msg.Attempt,
+			"kind":    KindOf(err),
+		})
+
+		if policy.ShouldRetry(msg.Attempt, err) {
+			if retryErr := a.scheduleRetry(ctx, msg, policy); retryErr != nil {
+				log.WithError(retryErr).Error("Failed to schedule command retry")
+				return Requeue
+			}
+
+			log.Warn("Command failed, scheduled retry")
+			return Ack
+		}
+
+		log.Error("Command failed, sending to DLQ")
+		return Reject
 	}
 
-	if handler.successEvent != "" {
-		if err := a.sendReply(context.Background(), msg.CorrelationID, handler.successEvent, handler.replyQueue, result); err != nil {
+	if out.event != "" && !out.replied {
+		if err := a.sendReply(ctx, msg, out.event, handler.replyQueue, out.payload); err != nil {
 			logrus.WithError(err).Error("Failed to send reply event")
-			return gorabbit.NackRequeue
+			return Requeue
 		}
 	}
 
-	return gorabbit.Ack
+	return Ack
 }
 
-func (a *Actor) sendReply(ctx context.Context, correlationID, eventType, queue string, payload any) error {
-	if eventType == "" {
-		return nil
+// execute runs the handler, inside a transaction when the actor has one, and
+// decides which reply to send. A reply that was already written to the outbox
+// is marked as replied and must not be published directly.
+func (a *Actor) execute(ctx context.Context, msg *Message, h actorHandler) (outcome, error) {
+	if a.tm == nil {
+		result, err := a.invoke(ctx, nil, msg, h)
+		if err != nil {
+			return a.failureOutcome(msg, h, err)
+		}
+
+		return outcome{event: h.successEvent, payload: result}, nil
 	}
 
-	replyMsg, err := NewSagaMessage(correlationID, eventType, payload)
+	var out outcome
+	err := a.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		if a.inbox != nil {
+			claimed, err := a.inbox.WithTx(tx).Claim(ctx, inboxKey(msg), msg.Type, msg.CorrelationID)
+			if err != nil {
+				return fmt.Errorf("claim inbox: %w", err)
+			}
+
+			if !claimed {
+				entry, err := a.inbox.WithTx(tx).Find(ctx, inboxKey(msg), msg.Type)
+				if err != nil {
+					return fmt.Errorf("find inbox entry: %w", err)
+				}
+
+				logrus.WithFields(logrus.Fields{
+					"correlation_id": msg.CorrelationID,
+					"cmd":            msg.Type,
+					"message_id":     inboxKey(msg),
+				}).Info("Duplicate command, replaying stored reply")
+
+				if entry != nil {
+					out.event = entry.ReplyEvent
+					if len(entry.ReplyPayload) > 0 {
+						out.payload = entry.ReplyPayload
+					}
+				}
+
+				// The original reply was committed to the outbox together
+				// with the inbox record, so there is nothing to replay.
+				out.replied = a.outboxRepo != nil
+				return nil
+			}
+		}
+
+		var err error
+		out, err = a.runHandler(ctx, tx, msg, h)
+		if err != nil {
+			return err
+		}
+
+		if a.outboxRepo != nil && out.event != "" {
+			if err := a.saveReply(ctx, tx, msg, out.event, h.replyQueue, out.payload); err != nil {
+				return err
+			}
+			out.replied = true
+		}
+
+		if a.inbox == nil {
+			return nil
+		}
+
+		replyPayload, err := json.Marshal(out.payload)
+		if err != nil {
+			return fmt.Errorf("marshal reply payload: %w", err)
+		}
+
+		return a.inbox.WithTx(tx).SaveReply(ctx, inboxKey(msg), msg.Type, out.event, replyPayload)
+	})
 	if err != nil {
-		return fmt.Errorf("create reply message: %w", err)
+		return outcome{}, err
+	}
+
+	return out, nil
+}
+
+// runHandler calls the handler within a savepoint, so a business failure
+// discards the handler's writes while the failure reply is still committed.
+func (a *Actor) runHandler(ctx context.Context, tx pgx.Tx, msg *Message, h actorHandler) (outcome, error) {
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return outcome{}, fmt.Errorf("begin savepoint: %w", err)
+	}
+
+	result, err := a.invoke(ctx, sp, msg, h)
+	if err != nil {
+		_ = sp.Rollback(ctx)
+		return a.failureOutcome(msg, h, err)
+	}
+
+	if err := sp.Commit(ctx); err != nil {
+		return outcome{}, fmt.Errorf("release savepoint: %w", err)
+	}
+
+	return outcome{event: h.successEvent, payload: result}, nil
+}
+
+// invoke runs the handler on a valid command.
+func (a *Actor) invoke(ctx context.Context, tx pgx.Tx, msg *Message, h actorHandler) (any, error) {
+	if err := a.schemas.Validate(msg); err != nil {
+		return nil, Fail(InvalidPayloadCode, err.Error())
 	}
 
+	return h.handler(ctx, tx, msg)
+}
+
+func (a *Actor) failureOutcome(msg *Message, h actorHandler, err error) (outcome, error) {
+	businessErr, ok := AsBusinessError(err)
+	if !ok || h.failureEvent == "" {
+		return outcome{}, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"correlation_id": msg.CorrelationID,
+		"cmd":            msg.Type,
+		"code":           businessErr.Code,
+	}).Warn("Command rejected, replying with failure event")
+
+	return outcome{
+		event: h.failureEvent,
+		payload: FailurePayload{
+			Command: msg.Type,
+			Code:    businessErr.Code,
+			Message: businessErr.Message,
+		},
+	}, nil
+}
+
+func (a *Actor) scheduleRetry(ctx context.Context, msg *Message, policy *RetryPolicy) error {
+	delay := policy.Backoff(msg.Attempt)
+
+	next := *msg
+	next.Attempt = msg.Attempt + 1
+
+	return a.transport.Publish(ctx, a.queue, &next, WithDelay(delay), WithPublishCodec(a.codec))
+}
+
+func (a *Actor) newReply(cmd *Message, eventType string, payload any) (*Message, error) {
+	replyMsg, err := NewSagaMessage(cmd.CorrelationID, eventType, payload, WithSource(a.source), WithCause(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("create reply message: %w", err)
+	}
+	replyMsg.Attempt = cmd.Attempt
+
+	return replyMsg, nil
+}
+
+func (a *Actor) saveReply(ctx context.Context, tx pgx.Tx, cmd *Message, eventType, queue string, payload any) error {
+	replyMsg, err := a.newReply(cmd, eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	// The outbox stores JSON; the outbox publisher encodes it for the wire.
 	raw, err := json.Marshal(replyMsg)
 	if err != nil {
 		return fmt.Errorf("marshal reply: %w", err)
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"correlation_id": correlationID,
+		"correlation_id": cmd.CorrelationID,
+		"event":          eventType,
+		"queue":          queue,
+	}).Info("Saving reply event to outbox")
+
+	return a.outboxRepo.WithTx(tx).Save(ctx, outbox.NewMessage(raw,
+		outbox.WithID(uuid.New()),
+		outbox.WithCreatedAt(time.Now()),
+		outbox.WithMetadata([]byte(queue)),
+	))
+}
+
+func (a *Actor) sendReply(ctx context.Context, cmd *Message, eventType, queue string, payload any) error {
+	if eventType == "" {
+		return nil
+	}
+
+	replyMsg, err := a.newReply(cmd, eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"correlation_id": cmd.CorrelationID,
 		"event":          eventType,
 		"queue":          queue,
 	}).Info("Publishing reply event")
 
-	return a.publisher.PublishWithContext(
-		ctx,
-		raw,
-		[]string{queue},
-		gorabbit.WithPublishOptionsContentType("application/json"),
-	)
+	return a.transport.Publish(ctx, queue, replyMsg, WithPublishCodec(a.codec))
 }
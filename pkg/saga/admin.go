@@ -0,0 +1,249 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrSagaNotFound     = errors.New("saga not found")
+	ErrStepNotFound     = errors.New("saga step not found")
+	ErrInvalidSagaState = errors.New("action not allowed in current saga state")
+)
+
+const defaultListLimit = 50
+
+type SagaFilter struct {
+	Workflow      string
+	Status        SagaStateStatus
+	CreatedBefore *time.Time
+	CreatedAfter  *time.Time
+	Limit         int
+	Offset        int
+}
+
+type SagaDetails struct {
+	State    SagaStateEntity
+	Steps    []SagaStep
+	Children []SagaStateEntity
+}
+
+func (c *Coordinator) ListSagas(ctx context.Context, filter SagaFilter) ([]SagaStateEntity, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+
+	return c.repo.List(ctx, filter)
+}
+
+func (c *Coordinator) GetSaga(ctx context.Context, correlationID string) (*SagaDetails, error) {
+	state, err := c.repo.FindByCorrelationID(ctx, correlationID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, ErrSagaNotFound
+	}
+
+	steps, err := c.repo.GetSteps(ctx, state.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get steps: %w", err)
+	}
+
+	children, err := c.repo.ListChildren(ctx, state.CorrelationID)
+	if err != nil {
+		return nil, fmt.Errorf("list children: %w", err)
+	}
+
+	return &SagaDetails{
+		State:    *state,
+		Steps:    steps,
+		Children: children,
+	}, nil
+}
+
+// RetryStep re-sends a failed command with its original payload. Forward steps
+// can be retried while the saga is still running; compensation steps can be
+// retried once the saga ended up in COMPENSATION_FAILED.
+func (c *Coordinator) RetryStep(ctx context.Context, correlationID, stepName string) error {
+	details, err := c.GetSaga(ctx, correlationID)
+	if err != nil {
+		return err
+	}
+
+	state := &details.State
+
+	step, ok := lastStep(details.Steps, stepName)
+	if !ok {
+		return ErrStepNotFound
+	}
+
+	if step.Status != StepStatusFailed {
+		return fmt.Errorf("%w: step %s is %s", ErrInvalidSagaState, stepName, step.Status)
+	}
+
+	var next SagaStateStatus
+	switch {
+	case step.Compensation && state.Status == SagaStateCompensationFailed:
+		next = SagaStateCompensating
+	case !step.Compensation && state.Status == SagaStateStarted:
+		next = SagaStateStarted
+	default:
+		return fmt.Errorf("%w: saga is %s", ErrInvalidSagaState, state.Status)
+	}
+
+	wf, err := c.workflowFor(state)
+	if err != nil {
+		return err
+	}
+
+	dest, ok := wf.commandDest[stepName]
+	if !ok {
+		return fmt.Errorf("destination not found for: %s", stepName)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"correlation_id": correlationID,
+		"step":           stepName,
+	}).Info("Manually retrying saga step")
+
+	kind := HistoryCommandSent
+	if step.Compensation {
+		kind = HistoryCompensationSent
+	}
+
+	return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		if err := c.publishOutboxCommand(ctx, tx, kind, dest, correlationID, stepName, step.Payload); err != nil {
+			return err
+		}
+
+		if err := c.repo.WithTx(tx).ResetStep(ctx, state.ID, stepName, dest.deadline()); err != nil {
+			return err
+		}
+
+		state.Status = next
+
+		// Fire-and-forget compensations are never acknowledged, so like in
+		// runCompensations they are done once dispatched.
+		if step.Compensation && wf.compensationCommands[stepName].SuccessEvent == "" {
+			if err := c.repo.WithTx(tx).MarkCompensated(ctx, state.ID, stepName, step.Attempts); err != nil {
+				return err
+			}
+
+			return c.settleCompensation(ctx, tx, state)
+		}
+
+		return c.repo.WithTx(tx).Update(ctx, state)
+	})
+}
+
+// ForceCompensate fails the pending steps of a running saga and runs the
+// compensations of its latest step.
+func (c *Coordinator) ForceCompensate(ctx context.Context, correlationID, reason string) error {
+	details, err := c.GetSaga(ctx, correlationID)
+	if err != nil {
+		return err
+	}
+
+	state := &details.State
+	if state.Status != SagaStateStarted {
+		return fmt.Errorf("%w: saga is %s", ErrInvalidSagaState, state.Status)
+	}
+
+	var latest *SagaStep
+	for i := range details.Steps {
+		if !details.Steps[i].Compensation {
+			latest = &details.Steps[i]
+		}
+	}
+	if latest == nil {
+		return fmt.Errorf("%w: saga has no steps", ErrNoCompensation)
+	}
+
+	wf, err := c.workflowFor(state)
+	if err != nil {
+		return err
+	}
+
+	comps := c.compensationsFor(wf, state, latest.StepName)
+	if len(comps) == 0 {
+		return fmt.Errorf("%w for step: %s", ErrNoCompensation, latest.StepName)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"correlation_id": correlationID,
+		"reason":         reason,
+	}).Warn("Force-compensating saga")
+
+	return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		if err := c.failPendingSteps(ctx, tx, state.ID, details.Steps, "Force-compensated: "+reason); err != nil {
+			return err
+		}
+
+		return c.runCompensations(ctx, tx, wf, state, comps)
+	})
+}
+
+// Abort stops a saga without compensating it.
+func (c *Coordinator) Abort(ctx context.Context, correlationID, reason string) error {
+	details, err := c.GetSaga(ctx, correlationID)
+	if err != nil {
+		return err
+	}
+
+	state := &details.State
+	if state.Status.IsTerminal() {
+		return fmt.Errorf("%w: saga is %s", ErrInvalidSagaState, state.Status)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"correlation_id": correlationID,
+		"reason":         reason,
+	}).Warn("Aborting saga")
+
+	return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		if err := c.failPendingSteps(ctx, tx, state.ID, details.Steps, "Aborted: "+reason); err != nil {
+			return err
+		}
+
+		if err := c.cancelScheduled(ctx, tx, state); err != nil {
+			return err
+		}
+
+		state.Status = SagaStateAborted
+		if err := c.repo.WithTx(tx).Update(ctx, state); err != nil {
+			return err
+		}
+
+		return c.notifyParent(ctx, tx, state)
+	})
+}
+
+func (c *Coordinator) failPendingSteps(ctx context.Context, tx pgx.Tx, sagaStateID string, steps []SagaStep, errMsg string) error {
+	for _, step := range steps {
+		if step.Status != StepStatusPending {
+			continue
+		}
+
+		if err := c.repo.WithTx(tx).UpdateStep(ctx, sagaStateID, step.StepName, StepStatusFailed, 0, errMsg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func lastStep(steps []SagaStep, name string) (SagaStep, bool) {
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].StepName == name {
+			return steps[i], true
+		}
+	}
+
+	return SagaStep{}, false
+}
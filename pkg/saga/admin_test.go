@@ -0,0 +1,60 @@
+package saga_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+)
+
+func TestRetryFireAndForgetCompensation(t *testing.T) {
+	h := sagatest.New(t)
+
+	h.Coordinator().Workflow("shipping", 1).
+		StartOn("paid").
+		RegisterStep(saga.StepDefinition{
+			Command:       "cmd.ship",
+			Queue:         "shipping",
+			SuccessEvent:  "shipped",
+			FailureEvent:  "ship_failed",
+			Compensations: []string{"cmd.refund", "cmd.restock"},
+		}).
+		RegisterCompensationQueue("cmd.refund", "payments").
+		RegisterCompensation(saga.CompensationDefinition{
+			Command:      "cmd.restock",
+			Queue:        "stock",
+			SuccessEvent: "restocked",
+		}).
+		On("paid", func(_ context.Context, e *saga.Event) error {
+			return e.SendCommand("cmd.ship", nil)
+		})
+
+	h.Actor("shipping").Register("cmd.ship", nil, "shipped", "events", saga.WithFailureEvent("ship_failed"))
+	h.Actor("payments").Register("cmd.refund", nil, "", "events")
+	h.FailCommand("cmd.ship", saga.Fail("no_stock", "out of stock"), 1)
+	h.FailCommand("cmd.refund", errors.New("payments down"), 1)
+
+	h.Emit("paid", "order-1", nil)
+
+	h.AssertStatus("order-1", saga.SagaStateCompensating).
+		AssertStepStatus("order-1", "cmd.refund", saga.StepStatusFailed)
+
+	h.Actor("stock").Register("cmd.restock", nil, "restocked", "events")
+	h.Deliver()
+
+	h.AssertStatus("order-1", saga.SagaStateCompensationFailed)
+
+	if err := h.Coordinator().RetryStep(context.Background(), "order-1", "cmd.refund"); err != nil {
+		t.Fatalf("retry refund: %v", err)
+	}
+	h.Deliver()
+
+	h.AssertStatus("order-1", saga.SagaStateCompensated).
+		AssertStepStatus("order-1", "cmd.refund", saga.StepStatusCompleted)
+
+	if sent := len(h.CommandsOf("cmd.refund")); sent != 2 {
+		t.Errorf("expected the refund to be sent twice, sent %d times", sent)
+	}
+}
@@ -2,57 +2,233 @@ package saga
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/sirupsen/logrus"
 	gorabbit "github.com/wagslane/go-rabbitmq"
 	"go.uber.org/fx"
+
+	"soa-video-streaming/pkg/rabbitmq"
 )
 
-func ModuleRabbitMQEventsController() fx.Option {
+// ModuleAMQPTransport provides the RabbitMQ Transport. It is closed when the
+// application stops.
+func ModuleAMQPTransport() fx.Option {
 	return fx.Options(
-		fx.Provide(NewRabbitMQEventsController),
+		fx.Provide(func(lc fx.Lifecycle, client *rabbitmq.Client) (Transport, error) {
+			t, err := NewAMQPTransport(client)
+			if err != nil {
+				return nil, err
+			}
+
+			lc.Append(fx.Hook{OnStop: t.Close})
+			return t, nil
+		}),
+	)
+}
+
+// AMQPTransport carries saga messages over RabbitMQ queues. Delayed messages
+// wait in a retry queue of their queue until they expire and are
+// dead-lettered back onto it. Each delay gets its own retry queue with the TTL
+// set on the queue, so a long backoff never holds up a shorter one behind it.
+type AMQPTransport struct {
+	client     *rabbitmq.Client
+	publisher  *gorabbit.Publisher
+	codec      Codec
+	processor  *rabbitmq.Processor
+	dispatcher *Dispatcher
+
+	mu        sync.Mutex
+	consumers []*gorabbit.Consumer
+
+	retryMu     sync.Mutex
+	retryQueues map[string]bool
+}
+
+func NewAMQPTransport(client *rabbitmq.Client) (*AMQPTransport, error) {
+	publisher, err := gorabbit.NewPublisher(
+		client.Conn,
+		gorabbit.WithPublisherOptionsLogger(logrus.StandardLogger()),
 	)
+	if err != nil {
+		return nil, fmt.Errorf("create saga publisher: %w", err)
+	}
+
+	return &AMQPTransport{
+		client:      client,
+		publisher:   publisher,
+		codec:       CodecFor(client.ContentType()),
+		processor:   client.NewProcessor(),
+		dispatcher:  NewDispatcher(client.Concurrency()),
+		retryQueues: make(map[string]bool),
+	}, nil
 }
 
-type RabbitMQEventsController struct {
-	coordinator *Coordinator
+// RetryQueueName is the queue messages for queue wait in for delay.
+func RetryQueueName(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%d", queue, retryTTL(delay))
 }
 
-func NewRabbitMQEventsController(coordinator *Coordinator) *RabbitMQEventsController {
-	return &RabbitMQEventsController{
-		coordinator: coordinator,
+// retryTTL is delay in whole milliseconds, rounded up so no message is
+// delivered early.
+func retryTTL(delay time.Duration) int64 {
+	return int64((delay + time.Millisecond - 1) / time.Millisecond)
+}
+
+func (t *AMQPTransport) Publish(ctx context.Context, queue string, msg *Message, opts ...PublishOption) error {
+	o := publishOptions(opts, t.codec)
+
+	body, pubOpts, err := EncodeMessage(msg, o.Codec)
+	if err != nil {
+		return err
+	}
+
+	if o.Delay > 0 {
+		queue, err = t.retryQueue(queue, o.Delay)
+		if err != nil {
+			return err
+		}
+		pubOpts = append(pubOpts, gorabbit.WithPublishOptionsPersistentDelivery)
+	}
+
+	return t.publisher.PublishWithContext(ctx, body, []string{queue}, pubOpts...)
+}
+
+// retryQueue declares the retry queue of queue for delay the first time it is
+// used. Delays come from the retry policies, so there are only a few of them.
+func (t *AMQPTransport) retryQueue(queue string, delay time.Duration) (string, error) {
+	name := RetryQueueName(queue, delay)
+
+	t.retryMu.Lock()
+	defer t.retryMu.Unlock()
+
+	if t.retryQueues[name] {
+		return name, nil
+	}
+
+	err := t.client.CreateQueueWithArgs(name, true, amqp.Table{
+		"x-message-ttl":             retryTTL(delay),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": queue,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create retry queue: %w", err)
 	}
+
+	t.retryQueues[name] = true
+	return name, nil
 }
 
-func (c *RabbitMQEventsController) HandleEvent(d gorabbit.Delivery) gorabbit.Action {
-	var msg Message
-	if err := json.Unmarshal(d.Body, &msg); err != nil {
-		logrus.WithError(err).Error("Failed to unmarshal event")
-		return gorabbit.NackDiscard
+func (t *AMQPTransport) Subscribe(queue string, h Handler, opts ...SubscribeOption) error {
+	o := subscribeOptions(opts)
+
+	consumerOpts := []func(*gorabbit.ConsumerOptions){
+		gorabbit.WithConsumerOptionsLogger(logrus.StandardLogger()),
+		gorabbit.WithConsumerOptionsQueueDurable,
+	}
+	if o.DeadLetterQueue != "" {
+		consumerOpts = append(consumerOpts, gorabbit.WithConsumerOptionsQueueArgs(map[string]any{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": o.DeadLetterQueue,
+		}))
+	}
+	consumerOpts = append(consumerOpts, t.client.ConsumerOptions(true)...)
+
+	consumer, err := gorabbit.NewConsumer(t.client.Conn, queue, consumerOpts...)
+	if err != nil {
+		return fmt.Errorf("create consumer: %w", err)
 	}
 
-	if err := c.coordinator.HandleEvent(context.Background(), &msg); err != nil {
-		logrus.WithError(err).Error("Failed to handle saga event")
-		return gorabbit.NackDiscard
+	t.mu.Lock()
+	t.consumers = append(t.consumers, consumer)
+	t.mu.Unlock()
+
+	go func() {
+		if err := consumer.Run(t.consume(h)); err != nil {
+			logrus.WithError(err).WithField("queue", queue).Error("Consumer stopped")
+		}
+	}()
+
+	return nil
+}
+
+// Close lets in-flight handlers finish before the channels go away, so their
+// acks and replies are not lost. Unsettled deliveries are redelivered by the
+// broker.
+func (t *AMQPTransport) Close(ctx context.Context) error {
+	t.processor.Drain(ctx)
+	t.dispatcher.Close(ctx)
+
+	t.mu.Lock()
+	consumers := t.consumers
+	t.mu.Unlock()
+
+	for _, consumer := range consumers {
+		consumer.CloseWithContext(ctx)
 	}
 
-	return gorabbit.Ack
+	t.publisher.Close()
+	return nil
 }
 
-func (c *RabbitMQEventsController) HandleFailure(d gorabbit.Delivery) gorabbit.Action {
-	var msg Message
-	if err := json.Unmarshal(d.Body, &msg); err != nil {
-		logrus.WithError(err).Error("Failed to unmarshal failure message")
-		return gorabbit.NackDiscard
+// consume hands deliveries to the dispatcher, which settles them once h
+// returns.
+func (t *AMQPTransport) consume(h Handler) gorabbit.Handler {
+	handle := t.processor.Handler(func(ctx context.Context, d gorabbit.Delivery) gorabbit.Action {
+		msg, err := DecodeMessage(d)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to unmarshal message")
+			return gorabbit.NackDiscard
+		}
+
+		switch h(ctx, msg) {
+		case Requeue:
+			return gorabbit.NackRequeue
+		case Reject:
+			return gorabbit.NackDiscard
+		}
+
+		return gorabbit.Ack
+	})
+
+	return func(d gorabbit.Delivery) gorabbit.Action {
+		if !t.dispatcher.Dispatch(deliveryCorrelationID(d), func() { settle(d, handle(d)) }) {
+			return gorabbit.NackRequeue
+		}
+
+		return gorabbit.Manual
+	}
+}
+
+func deliveryCorrelationID(d gorabbit.Delivery) string {
+	if d.CorrelationId != "" {
+		return d.CorrelationId
+	}
+
+	// Producers that do not set the AMQP property
+	if msg, err := DecodeMessage(d); err == nil {
+		return msg.CorrelationID
 	}
 
-	logrus.WithField("correlation_id", msg.CorrelationID).
-		Infof("Processing failure from DLQ for command: %s", msg.Type)
+	return ""
+}
+
+func settle(d gorabbit.Delivery, action gorabbit.Action) {
+	var err error
 
-	if err := c.coordinator.HandleFailure(context.Background(), &msg); err != nil {
-		logrus.WithError(err).Error("Failed to compensate saga")
-		return gorabbit.NackDiscard
+	switch action {
+	case gorabbit.Ack:
+		err = d.Ack(false)
+	case gorabbit.NackDiscard:
+		err = d.Nack(false, false)
+	case gorabbit.NackRequeue:
+		err = d.Nack(false, true)
 	}
 
-	return gorabbit.Ack
+	if err != nil {
+		logrus.WithError(err).WithField("correlation_id", d.CorrelationId).Error("Failed to settle delivery")
+	}
 }
@@ -0,0 +1,23 @@
+package saga
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryQueueNamePerDelay(t *testing.T) {
+	cases := []struct {
+		delay time.Duration
+		want  string
+	}{
+		{time.Second, "orders.retry.1000"},
+		{30 * time.Second, "orders.retry.30000"},
+		{1500 * time.Microsecond, "orders.retry.2"},
+	}
+
+	for _, c := range cases {
+		if got := RetryQueueName("orders", c.delay); got != c.want {
+			t.Errorf("RetryQueueName(%v) = %q, want %q", c.delay, got, c.want)
+		}
+	}
+}
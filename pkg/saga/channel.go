@@ -0,0 +1,281 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+var ErrTransportClosed = errors.New("transport closed")
+
+const defaultChannelBuffer = 1024
+
+// ModuleChannelTransport provides the in-process Transport, so the
+// coordinator and its actors can run in one binary without a broker.
+func ModuleChannelTransport(opts ...ChannelOption) fx.Option {
+	return fx.Options(
+		fx.Provide(func(lc fx.Lifecycle) Transport {
+			t := NewChannelTransport(opts...)
+			lc.Append(fx.Hook{OnStop: t.Close})
+			return t
+		}),
+	)
+}
+
+type ChannelOption func(t *ChannelTransport)
+
+// WithChannelWorkers sets the number of sagas handled in parallel.
+func WithChannelWorkers(n int) ChannelOption {
+	return func(t *ChannelTransport) {
+		t.workers = n
+	}
+}
+
+// WithChannelHandlerTimeout bounds the context of each handled message.
+func WithChannelHandlerTimeout(d time.Duration) ChannelOption {
+	return func(t *ChannelTransport) {
+		t.timeout = d
+	}
+}
+
+// WithChannelBuffer sets how many messages a queue holds before publishers
+// block.
+func WithChannelBuffer(n int) ChannelOption {
+	return func(t *ChannelTransport) {
+		t.buffer = n
+	}
+}
+
+// WithChannelCodec sets the default codec. Messages are encoded on publish
+// and decoded on delivery, so handlers never share a message with the
+// publisher.
+func WithChannelCodec(codec Codec) ChannelOption {
+	return func(t *ChannelTransport) {
+		t.codec = codec
+	}
+}
+
+// ChannelTransport carries saga messages over Go channels within one
+// process. Queues are not durable: messages still queued or delayed when it
+// closes are lost.
+type ChannelTransport struct {
+	codec   Codec
+	workers int
+	timeout time.Duration
+	buffer  int
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	stopped    chan struct{}
+	dispatcher *Dispatcher
+
+	mu       sync.Mutex
+	closed   bool
+	queues   map[string]chan channelMessage
+	inFlight sync.WaitGroup
+	readers  sync.WaitGroup
+}
+
+type channelMessage struct {
+	body        []byte
+	contentType string
+}
+
+func NewChannelTransport(opts ...ChannelOption) *ChannelTransport {
+	t := &ChannelTransport{
+		codec:   JSONCodec{},
+		workers: 1,
+		buffer:  defaultChannelBuffer,
+		stopped: make(chan struct{}),
+		queues:  make(map[string]chan channelMessage),
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.dispatcher = NewDispatcher(t.workers)
+
+	return t
+}
+
+func (t *ChannelTransport) Publish(ctx context.Context, queue string, msg *Message, opts ...PublishOption) error {
+	o := publishOptions(opts, t.codec)
+
+	body, err := o.Codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	m := channelMessage{body: body, contentType: o.Codec.ContentType()}
+
+	if o.Delay > 0 {
+		time.AfterFunc(o.Delay, func() {
+			if err := t.enqueue(t.ctx, queue, m); err != nil && !errors.Is(err, ErrTransportClosed) {
+				logrus.WithError(err).WithField("queue", queue).Error("Failed to deliver delayed message")
+			}
+		})
+		return nil
+	}
+
+	return t.enqueue(ctx, queue, m)
+}
+
+func (t *ChannelTransport) Subscribe(queue string, h Handler, opts ...SubscribeOption) error {
+	o := subscribeOptions(opts)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return ErrTransportClosed
+	}
+
+	messages := t.queueLocked(queue)
+
+	t.readers.Add(1)
+	go t.read(queue, messages, h, o)
+
+	return nil
+}
+
+// Close stops the readers and waits for in-flight handlers. When ctx ends
+// first, their contexts are cancelled and Close waits for them to return.
+func (t *ChannelTransport) Close(ctx context.Context) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.stopped)
+	t.mu.Unlock()
+
+	t.readers.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		t.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logrus.Warn("Shutdown deadline reached, cancelling in-flight handlers")
+		t.cancel()
+		<-done
+	}
+
+	t.cancel()
+	t.dispatcher.Close(ctx)
+
+	return nil
+}
+
+func (t *ChannelTransport) queueLocked(queue string) chan channelMessage {
+	messages, ok := t.queues[queue]
+	if !ok {
+		messages = make(chan channelMessage, t.buffer)
+		t.queues[queue] = messages
+	}
+
+	return messages
+}
+
+func (t *ChannelTransport) enqueue(ctx context.Context, queue string, m channelMessage) error {
+	t.mu.Lock()
+	messages := t.queueLocked(queue)
+	t.mu.Unlock()
+
+	select {
+	case messages <- m:
+		return nil
+	case <-t.stopped:
+		return ErrTransportClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// read feeds one queue to the dispatcher from a single goroutine, so the
+// messages of a saga keep their queue order.
+func (t *ChannelTransport) read(queue string, messages <-chan channelMessage, h Handler, o SubscribeOptions) {
+	defer t.readers.Done()
+
+	for {
+		select {
+		case <-t.stopped:
+			return
+		case m := <-messages:
+			msg := &Message{}
+			if err := CodecFor(m.contentType).Unmarshal(m.body, msg); err != nil {
+				logrus.WithError(err).WithField("queue", queue).Error("Failed to unmarshal message")
+				continue
+			}
+
+			if !t.track() {
+				return
+			}
+
+			t.dispatcher.Dispatch(msg.CorrelationID, func() {
+				defer t.inFlight.Done()
+				t.settle(queue, m, msg, t.handle(h, msg), o)
+			})
+		}
+	}
+}
+
+// track counts a message as in flight unless the transport is closing.
+func (t *ChannelTransport) track() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return false
+	}
+
+	t.inFlight.Add(1)
+	return true
+}
+
+func (t *ChannelTransport) handle(h Handler, msg *Message) Action {
+	ctx, cancel := t.ctx, context.CancelFunc(func() {})
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+	}
+	defer cancel()
+
+	return h(ctx, msg)
+}
+
+func (t *ChannelTransport) settle(queue string, m channelMessage, msg *Message, action Action, o SubscribeOptions) {
+	log := logrus.WithFields(logrus.Fields{
+		"queue":          queue,
+		"correlation_id": msg.CorrelationID,
+		"type":           msg.Type,
+	})
+
+	switch action {
+	case Ack:
+		return
+	case Reject:
+		if o.DeadLetterQueue == "" {
+			log.Warn("Message rejected, dropping it")
+			return
+		}
+		queue = o.DeadLetterQueue
+	}
+
+	err := t.enqueue(context.Background(), queue, m)
+	if errors.Is(err, ErrTransportClosed) {
+		log.Warn("Transport closed before the message was settled, dropping it")
+	} else if err != nil {
+		log.WithError(err).Error("Failed to settle message")
+	}
+}
@@ -0,0 +1,379 @@
+package saga_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oagudo/outbox"
+	"go.uber.org/fx/fxtest"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+)
+
+const waitTimeout = 5 * time.Second
+
+func newTransport(t *testing.T, opts ...saga.ChannelOption) *saga.ChannelTransport {
+	t.Helper()
+
+	transport := saga.NewChannelTransport(opts...)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+		defer cancel()
+
+		_ = transport.Close(ctx)
+	})
+
+	return transport
+}
+
+// collect subscribes to queue and hands every message to the returned
+// channel.
+func collect(t *testing.T, transport saga.Transport, queue string) <-chan *saga.Message {
+	t.Helper()
+
+	messages := make(chan *saga.Message, 64)
+	err := transport.Subscribe(queue, func(_ context.Context, msg *saga.Message) saga.Action {
+		messages <- msg
+		return saga.Ack
+	})
+	if err != nil {
+		t.Fatalf("subscribe %s: %v", queue, err)
+	}
+
+	return messages
+}
+
+func receive(t *testing.T, messages <-chan *saga.Message) *saga.Message {
+	t.Helper()
+
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(waitTimeout):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func publish(t *testing.T, transport saga.Transport, queue, correlationID, msgType string, payload any) {
+	t.Helper()
+
+	msg, err := saga.NewSagaMessage(correlationID, msgType, payload)
+	if err != nil {
+		t.Fatalf("create %s: %v", msgType, err)
+	}
+
+	if err := transport.Publish(context.Background(), queue, msg); err != nil {
+		t.Fatalf("publish %s: %v", msgType, err)
+	}
+}
+
+func TestChannelTransportKeepsSagaOrder(t *testing.T) {
+	transport := newTransport(t, saga.WithChannelWorkers(4))
+
+	const sagas, perSaga = 8, 50
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string][]int)
+		wg   sync.WaitGroup
+	)
+	wg.Add(sagas * perSaga)
+
+	err := transport.Subscribe("orders", func(_ context.Context, msg *saga.Message) saga.Action {
+		defer wg.Done()
+
+		var seq int
+		if err := json.Unmarshal(msg.Payload, &seq); err != nil {
+			t.Errorf("unmarshal payload: %v", err)
+		}
+
+		mu.Lock()
+		seen[msg.CorrelationID] = append(seen[msg.CorrelationID], seq)
+		mu.Unlock()
+
+		return saga.Ack
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	for seq := range perSaga {
+		for i := range sagas {
+			publish(t, transport, "orders", fmt.Sprintf("saga-%d", i), "step", seq)
+		}
+	}
+
+	wait(t, &wg)
+
+	for cid, seqs := range seen {
+		if !slices.IsSorted(seqs) || len(seqs) != perSaga {
+			t.Errorf("saga %s handled out of order: %v", cid, seqs)
+		}
+	}
+}
+
+func TestChannelTransportSettlesRequeueAndReject(t *testing.T) {
+	transport := newTransport(t)
+
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	err := transport.Subscribe("orders", func(_ context.Context, _ *saga.Message) saga.Action {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		if attempts == 1 {
+			return saga.Requeue
+		}
+
+		return saga.Reject
+	}, saga.WithDeadLetterQueue("orders.dlq"))
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	dead := collect(t, transport, "orders.dlq")
+
+	publish(t, transport, "orders", "saga-1", "step", nil)
+
+	if msg := receive(t, dead); msg.CorrelationID != "saga-1" {
+		t.Errorf("dead-lettered %s, want saga-1", msg.CorrelationID)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Errorf("handled %d times, want a requeue and a reject", attempts)
+	}
+}
+
+func TestChannelTransportDelaysMessages(t *testing.T) {
+	transport := newTransport(t)
+	messages := collect(t, transport, "orders")
+
+	msg, _ := saga.NewSagaMessage("saga-1", "step", nil)
+
+	start := time.Now()
+	if err := transport.Publish(context.Background(), "orders", msg, saga.WithDelay(50*time.Millisecond)); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	receive(t, messages)
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("delivered after %s, want at least 50ms", elapsed)
+	}
+}
+
+func TestActorRetriesWithBackoff(t *testing.T) {
+	transport := newTransport(t, saga.WithChannelWorkers(2))
+	replies := collect(t, transport, "events")
+
+	var (
+		mu       sync.Mutex
+		attempts []int
+	)
+
+	lc := fxtest.NewLifecycle(t)
+	actor := saga.NewActor(lc, transport, nil, "payments")
+	actor.Register("cmd.charge", func(_ context.Context, msg *saga.Message) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts = append(attempts, msg.Attempt)
+		if msg.Attempt < 3 {
+			return nil, errors.New("gateway unavailable")
+		}
+
+		return map[string]string{"charge_id": "ch-1"}, nil
+	}, "charged", "events", saga.WithRetryPolicy(saga.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 20 * time.Millisecond,
+	}))
+	lc.RequireStart()
+	t.Cleanup(lc.RequireStop)
+
+	start := time.Now()
+	publish(t, transport, "payments", "saga-1", "cmd.charge", nil)
+
+	reply := receive(t, replies)
+	if reply.Type != "charged" || reply.Attempt != 3 {
+		t.Errorf("got %s on attempt %d, want charged on attempt 3", reply.Type, reply.Attempt)
+	}
+
+	// 20ms before the second attempt, 40ms before the third
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("replied after %s, want the backoff of at least 60ms", elapsed)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(attempts, []int{1, 2, 3}) {
+		t.Errorf("attempts %v, want [1 2 3]", attempts)
+	}
+}
+
+func TestActorDeadLettersExhaustedRetries(t *testing.T) {
+	transport := newTransport(t)
+	dead := collect(t, transport, saga.DeadLetterQueue)
+
+	lc := fxtest.NewLifecycle(t)
+	actor := saga.NewActor(lc, transport, nil, "payments")
+	actor.Register("cmd.charge", func(_ context.Context, _ *saga.Message) (any, error) {
+		return nil, errors.New("gateway unavailable")
+	}, "charged", "events", saga.WithRetryPolicy(saga.RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	}))
+	lc.RequireStart()
+	t.Cleanup(lc.RequireStop)
+
+	publish(t, transport, "payments", "saga-1", "cmd.charge", nil)
+
+	msg := receive(t, dead)
+	if msg.Type != "cmd.charge" || msg.Attempt != 2 {
+		t.Errorf("dead-lettered %s on attempt %d, want cmd.charge on attempt 2", msg.Type, msg.Attempt)
+	}
+}
+
+// relayOutbox publishes outbox messages as soon as they are saved, standing
+// in for the outbox reader.
+type relayOutbox struct {
+	publisher *saga.OutboxPublisher
+}
+
+func (r *relayOutbox) Save(ctx context.Context, msg *outbox.Message) error {
+	return r.publisher.Publish(ctx, msg)
+}
+
+func (r *relayOutbox) WithTx(_ pgx.Tx) saga.OutboxRepository {
+	return r
+}
+
+// newCoordinator runs a coordinator on an in-memory store, consuming its
+// events and the dead-letter queue from transport.
+func newCoordinator(t *testing.T, transport saga.Transport) *sagatest.Repository {
+	t.Helper()
+
+	store := sagatest.NewStore()
+	repo := sagatest.NewRepository(store)
+	coordinator := saga.NewCoordinator(repo, sagatest.NewTransactionManager(store),
+		&relayOutbox{publisher: saga.NewOutboxPublisher(transport)})
+	coordinator.UseEventQueue("events")
+
+	events := saga.NewEventsController(coordinator)
+	if err := transport.Subscribe("events", events.HandleEvent); err != nil {
+		t.Fatalf("subscribe events: %v", err)
+	}
+	if err := transport.Subscribe(saga.DeadLetterQueue, events.HandleFailure); err != nil {
+		t.Fatalf("subscribe %s: %v", saga.DeadLetterQueue, err)
+	}
+
+	coordinator.Workflow("payment", 1).
+		StartOn("order.placed").
+		RegisterStep(saga.StepDefinition{
+			Command:       "cmd.charge",
+			Queue:         "payments",
+			SuccessEvent:  "charged",
+			Compensations: []string{"cmd.cancel_order"},
+		}).
+		RegisterCompensation(saga.CompensationDefinition{
+			Command:      "cmd.cancel_order",
+			Queue:        "orders",
+			SuccessEvent: "order.cancelled",
+		}).
+		On("order.placed", func(_ context.Context, e *saga.Event) error {
+			return e.SendCommand("cmd.charge", nil)
+		}).
+		On("charged", func(_ context.Context, e *saga.Event) error {
+			return e.Complete()
+		})
+
+	return repo
+}
+
+func startActor(t *testing.T, transport saga.Transport, queue, cmdType, successEvent string, handler saga.CommandHandler) {
+	t.Helper()
+
+	lc := fxtest.NewLifecycle(t)
+	actor := saga.NewActor(lc, transport, nil, queue)
+	actor.Register(cmdType, handler, successEvent, "events")
+	lc.RequireStart()
+	t.Cleanup(lc.RequireStop)
+}
+
+func waitForStatus(t *testing.T, repo *sagatest.Repository, correlationID string, status saga.SagaStateStatus) {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		state, err := repo.FindByCorrelationID(context.Background(), correlationID)
+		if err != nil {
+			t.Fatalf("find saga: %v", err)
+		}
+
+		if state != nil && state.Status == status {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("saga %s did not reach %s, got %+v", correlationID, status, state)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func succeed(_ context.Context, msg *saga.Message) (any, error) {
+	return msg.Payload, nil
+}
+
+func TestCoordinatorReplyRoundTrip(t *testing.T) {
+	// One worker, as the in-memory store is not transaction-isolated
+	transport := newTransport(t)
+	repo := newCoordinator(t, transport)
+
+	startActor(t, transport, "payments", "cmd.charge", "charged", succeed)
+
+	publish(t, transport, "events", "order-1", "order.placed", nil)
+
+	waitForStatus(t, repo, "order-1", saga.SagaStateCompleted)
+}
+
+func TestCoordinatorCompensatesDeadLetteredCommand(t *testing.T) {
+	transport := newTransport(t)
+	repo := newCoordinator(t, transport)
+
+	startActor(t, transport, "payments", "cmd.charge", "charged", func(_ context.Context, _ *saga.Message) (any, error) {
+		return nil, saga.Permanent(errors.New("card declined"))
+	})
+	startActor(t, transport, "orders", "cmd.cancel_order", "order.cancelled", succeed)
+
+	publish(t, transport, "events", "order-1", "order.placed", nil)
+
+	waitForStatus(t, repo, "order-1", saga.SagaStateCompensated)
+}
+
+func wait(t *testing.T, wg *sync.WaitGroup) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(waitTimeout):
+		t.Fatal("messages still being handled")
+	}
+}
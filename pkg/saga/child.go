@@ -0,0 +1,237 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrNoEventQueue = errors.New("saga event queue is not configured")
+
+const childStepPrefix = "saga:"
+
+// ChildDefinition declares a workflow the saga runs as a child saga. The
+// child runs with its own correlation ID and reports back to the parent with
+// ChildCompletedEvent or ChildFailedEvent. A failed child compensates the
+// parent with Compensations, like a failed step.
+type ChildDefinition struct {
+	Workflow string
+	// StartEvent is the event the child workflow starts on. It carries the
+	// payload passed to Event.StartChild.
+	StartEvent    string
+	Compensations []string
+	// Timeout is how long the parent waits for the child to finish.
+	Timeout time.Duration
+	// After is the event whose handler starts the child. It is only used to
+	// draw the workflow.
+	After string
+}
+
+// ChildCompletedEvent is delivered to the parent when a child saga of the
+// workflow completes. Its payload is the child's saga state.
+func ChildCompletedEvent(workflow string) string {
+	return "saga." + workflow + ".completed"
+}
+
+// ChildFailedEvent is delivered to the parent when a child saga of the
+// workflow is compensated or aborted. Its payload is a FailurePayload.
+func ChildFailedEvent(workflow string) string {
+	return "saga." + workflow + ".failed"
+}
+
+// ChildStepName is the step that tracks child sagas of the workflow in the
+// parent.
+func ChildStepName(workflow string) string {
+	return childStepPrefix + workflow
+}
+
+// isChildStep reports whether step tracks a child saga of wf.
+func (w *Workflow) isChildStep(step string) bool {
+	workflow, ok := strings.CutPrefix(step, childStepPrefix)
+	if !ok {
+		return false
+	}
+
+	_, ok = w.children[workflow]
+	return ok
+}
+
+// UseEventQueue sets the queue the coordinator consumes events from. Child
+// sagas are started and report back to their parent through it.
+func (c *Coordinator) UseEventQueue(queue string) {
+	c.eventQueue = queue
+}
+
+func (w *Workflow) RegisterChild(def ChildDefinition) *Workflow {
+	step := ChildStepName(def.Workflow)
+
+	w.children[def.Workflow] = def
+	w.eventToCommand[ChildCompletedEvent(def.Workflow)] = step
+	w.failureToCommand[ChildFailedEvent(def.Workflow)] = step
+
+	if len(def.Compensations) > 0 {
+		w.compensations[step] = def.Compensations
+	}
+
+	return w
+}
+
+// StartChild starts a child saga of a registered child workflow and returns
+// its correlation ID. The child is linked to this saga and started by its
+// start event, which is published through the outbox with this transaction.
+func (e *Event) StartChild(workflow string, payload any) (string, error) {
+	c := e.coordinator
+
+	def, ok := e.workflow.children[workflow]
+	if !ok {
+		return "", fmt.Errorf("child workflow not registered: %s", workflow)
+	}
+
+	if c.eventQueue == "" {
+		return "", ErrNoEventQueue
+	}
+
+	child, ok := c.startEvents[def.StartEvent]
+	if !ok || child.name != workflow {
+		return "", fmt.Errorf("workflow %s does not start on %s", workflow, def.StartEvent)
+	}
+
+	cause, _ := MessageFromContext(e.ctx)
+	correlationID := uuid.NewString()
+
+	msg, err := NewSagaMessage(correlationID, def.StartEvent, payload, WithSource(CoordinatorSource), WithCause(cause))
+	if err != nil {
+		return "", err
+	}
+
+	repo := c.repo.WithTx(e.tx)
+
+	state, err := repo.Create(e.ctx, correlationID, child.name, child.version, SagaStateStarted, nil)
+	if err != nil {
+		return "", fmt.Errorf("create child saga: %w", err)
+	}
+
+	if err := repo.SetParent(e.ctx, state.ID, e.sagaState.CorrelationID); err != nil {
+		return "", fmt.Errorf("link child saga: %w", err)
+	}
+
+	if _, err := c.saveOutbox(e.ctx, e.tx, c.eventQueue, msg, time.Now()); err != nil {
+		return "", err
+	}
+
+	err = repo.AddStep(e.ctx, SagaStep{
+		SagaStateID: e.sagaState.ID,
+		StepName:    ChildStepName(workflow),
+		ServiceName: CoordinatorSource,
+		Status:      StepStatusPending,
+		Payload:     msg.Payload,
+		DeadlineAt:  CommandDestination{Timeout: def.Timeout}.deadline(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	started, _ := json.Marshal(map[string]string{
+		"correlation_id": correlationID,
+		"start_event":    def.StartEvent,
+	})
+
+	err = repo.AppendHistory(e.ctx, HistoryEntry{
+		CorrelationID: e.sagaState.CorrelationID,
+		Kind:          HistoryChildStarted,
+		Type:          workflow,
+		MessageID:     correlationID,
+		Payload:       started,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return correlationID, nil
+}
+
+// notifyParent reports the outcome of a finished child saga to its parent.
+func (c *Coordinator) notifyParent(ctx context.Context, tx pgx.Tx, state *SagaStateEntity) error {
+	if state.ParentCorrelationID == "" {
+		return nil
+	}
+
+	if c.eventQueue == "" {
+		return ErrNoEventQueue
+	}
+
+	eventType, payload := ChildCompletedEvent(state.Workflow), any(state.Data)
+	if state.Status != SagaStateCompleted {
+		eventType = ChildFailedEvent(state.Workflow)
+		payload = FailurePayload{
+			Command: ChildStepName(state.Workflow),
+			Code:    string(state.Status),
+			Message: fmt.Sprintf("child saga %s ended %s", state.CorrelationID, state.Status),
+		}
+	}
+
+	cause, _ := MessageFromContext(ctx)
+
+	msg, err := NewSagaMessage(state.ParentCorrelationID, eventType, payload, WithSource(CoordinatorSource), WithCause(cause))
+	if err != nil {
+		return err
+	}
+
+	_, err = c.saveOutbox(ctx, tx, c.eventQueue, msg, time.Now())
+	return err
+}
+
+// SagaNode is a saga with its child sagas.
+type SagaNode struct {
+	State    SagaStateEntity
+	Children []*SagaNode
+}
+
+// SagaTree returns the whole parent/child tree the saga belongs to, starting
+// at its root.
+func (c *Coordinator) SagaTree(ctx context.Context, correlationID string) (*SagaNode, error) {
+	state, err := c.repo.FindByCorrelationID(ctx, correlationID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, ErrSagaNotFound
+	}
+
+	for state.ParentCorrelationID != "" {
+		parent, err := c.repo.FindByCorrelationID(ctx, state.ParentCorrelationID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			break
+		}
+		state = parent
+	}
+
+	return c.subtree(ctx, *state)
+}
+
+func (c *Coordinator) subtree(ctx context.Context, state SagaStateEntity) (*SagaNode, error) {
+	children, err := c.repo.ListChildren(ctx, state.CorrelationID)
+	if err != nil {
+		return nil, fmt.Errorf("list children of %s: %w", state.CorrelationID, err)
+	}
+
+	node := &SagaNode{State: state}
+	for _, child := range children {
+		childNode, err := c.subtree(ctx, child)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, childNode)
+	}
+
+	return node, nil
+}
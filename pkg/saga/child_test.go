@@ -0,0 +1,111 @@
+package saga_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+)
+
+// registerOrder runs invoicing as a child saga of the order. A failed invoice
+// cancels the order.
+func registerOrder(h *sagatest.Harness, childID *string) {
+	c := h.Coordinator()
+
+	c.Workflow("invoice", 1).
+		StartOn("invoice.requested").
+		RegisterStep(saga.StepDefinition{
+			Command:      "cmd.issue_invoice",
+			Queue:        "billing",
+			SuccessEvent: "invoice.issued",
+			FailureEvent: "invoice.failed",
+		}).
+		On("invoice.requested", func(_ context.Context, e *saga.Event) error {
+			return e.SendCommand("cmd.issue_invoice", nil)
+		}).
+		On("invoice.issued", func(_ context.Context, e *saga.Event) error {
+			if err := e.SetState(map[string]string{"invoice": "inv-1"}); err != nil {
+				return err
+			}
+
+			return e.Complete()
+		})
+
+	c.Workflow("order", 1).
+		StartOn("order.placed").
+		RegisterChild(saga.ChildDefinition{
+			Workflow:      "invoice",
+			StartEvent:    "invoice.requested",
+			Compensations: []string{"cmd.cancel_order"},
+		}).
+		RegisterCompensationQueue("cmd.cancel_order", "orders").
+		On("order.placed", func(_ context.Context, e *saga.Event) error {
+			id, err := e.StartChild("invoice", nil)
+			*childID = id
+			return err
+		}).
+		On(saga.ChildCompletedEvent("invoice"), func(_ context.Context, e *saga.Event) error {
+			var invoice map[string]string
+			if err := e.UnmarshalPayload(&invoice); err != nil {
+				return err
+			}
+			if err := e.SetState(invoice); err != nil {
+				return err
+			}
+
+			return e.Complete()
+		})
+
+	h.Actor("orders").Register("cmd.cancel_order", nil, "", "events")
+}
+
+func TestChildCompletionCompletesParent(t *testing.T) {
+	h := sagatest.New(t)
+
+	var childID string
+	registerOrder(h, &childID)
+	h.Actor("billing").Register("cmd.issue_invoice", nil, "invoice.issued", "events")
+
+	h.Emit("order.placed", "order-1", nil)
+
+	var state map[string]string
+	h.AssertStatus(childID, saga.SagaStateCompleted).
+		AssertStatus("order-1", saga.SagaStateCompleted).
+		AssertStepStatus("order-1", saga.ChildStepName("invoice"), saga.StepStatusCompleted).
+		AssertCommandNotSent("cmd.cancel_order").
+		GetState("order-1", &state)
+	if state["invoice"] != "inv-1" {
+		t.Errorf("child state not handed to the parent, state %v", state)
+	}
+
+	if parent := h.State(childID).ParentCorrelationID; parent != "order-1" {
+		t.Errorf("child linked to %q, want order-1", parent)
+	}
+
+	tree, err := h.Coordinator().SagaTree(context.Background(), childID)
+	if err != nil {
+		t.Fatalf("tree: %v", err)
+	}
+	if tree.State.CorrelationID != "order-1" || len(tree.Children) != 1 || tree.Children[0].State.CorrelationID != childID {
+		t.Errorf("unexpected saga tree rooted at %s with %d children", tree.State.CorrelationID, len(tree.Children))
+	}
+}
+
+func TestChildCompensationCompensatesParent(t *testing.T) {
+	h := sagatest.New(t)
+
+	var childID string
+	registerOrder(h, &childID)
+	h.Actor("billing").Register("cmd.issue_invoice", func(_ context.Context, _ *saga.Message) (any, error) {
+		return nil, saga.Permanent(errors.New("tax id rejected"))
+	}, "invoice.issued", "events", saga.WithFailureEvent("invoice.failed"))
+
+	h.Emit("order.placed", "order-1", nil)
+
+	h.AssertStatus(childID, saga.SagaStateCompensated).
+		AssertStatus("order-1", saga.SagaStateCompensated).
+		AssertStepStatus("order-1", saga.ChildStepName("invoice"), saga.StepStatusFailed).
+		AssertCommands("cmd.issue_invoice", "cmd.cancel_order")
+}
@@ -0,0 +1,210 @@
+package saga
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	sagav1 "soa-video-streaming/pkg/pb/saga"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Codec encodes saga messages for the wire. The AMQP content type tells the
+// consumer which codec to decode with.
+type Codec interface {
+	ContentType() string
+	Marshal(msg *Message) ([]byte, error)
+	Unmarshal(data []byte, msg *Message) error
+}
+
+var codecs = map[string]Codec{
+	ContentTypeJSON:     JSONCodec{},
+	ContentTypeProtobuf: ProtoCodec{},
+}
+
+// CodecFor returns the codec of a content type. Unknown and empty content
+// types fall back to JSON, which every producer used before protobuf.
+func CodecFor(contentType string) Codec {
+	if codec, ok := codecs[contentType]; ok {
+		return codec
+	}
+
+	return JSONCodec{}
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (JSONCodec) Marshal(msg *Message) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (JSONCodec) Unmarshal(data []byte, msg *Message) error {
+	return json.Unmarshal(data, msg)
+}
+
+var (
+	protoPayloadsMu sync.RWMutex
+	protoPayloads   = make(map[string]protoreflect.MessageType)
+)
+
+// RegisterProtoPayload sets the protobuf message that carries the payload of
+// msgType. Payloads of unregistered types travel as JSON inside the envelope.
+func RegisterProtoPayload(msgType string, payload proto.Message) {
+	protoPayloadsMu.Lock()
+	defer protoPayloadsMu.Unlock()
+
+	protoPayloads[msgType] = payload.ProtoReflect().Type()
+}
+
+func protoPayload(msgType string) (protoreflect.MessageType, bool) {
+	protoPayloadsMu.RLock()
+	defer protoPayloadsMu.RUnlock()
+
+	t, ok := protoPayloads[msgType]
+	return t, ok
+}
+
+// ProtoCodec encodes messages as a saga.v1.Envelope. Saga code keeps working
+// with JSON payloads; they are converted to and from the registered protobuf
+// message at the edge.
+type ProtoCodec struct{}
+
+func (ProtoCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (ProtoCodec) Marshal(msg *Message) ([]byte, error) {
+	env := &sagav1.Envelope{
+		Id:                msg.ID,
+		CorrelationId:     msg.CorrelationID,
+		CausationId:       msg.CausationID,
+		Type:              msg.Type,
+		SchemaVersion:     int32(msg.SchemaVersion),
+		Source:            msg.Source,
+		TimestampUnixNano: msg.Timestamp.UnixNano(),
+		Traceparent:       msg.TraceParent,
+		Tracestate:        msg.TraceState,
+		Attempt:           int32(msg.Attempt),
+		Retry:             retryToProto(msg.Retry),
+	}
+
+	t, ok := protoPayload(msg.Type)
+	if !ok || isNullJSON(msg.Payload) {
+		env.Body = &sagav1.Envelope_JsonPayload{JsonPayload: msg.Payload}
+		return proto.Marshal(env)
+	}
+
+	payload := t.New().Interface()
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(msg.Payload, payload); err != nil {
+		return nil, fmt.Errorf("convert %s payload to protobuf: %w", msg.Type, err)
+	}
+
+	raw, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s payload: %w", msg.Type, err)
+	}
+
+	env.Body = &sagav1.Envelope_Payload{Payload: raw}
+	return proto.Marshal(env)
+}
+
+func (ProtoCodec) Unmarshal(data []byte, msg *Message) error {
+	var env sagav1.Envelope
+	if err := proto.Unmarshal(data, &env); err != nil {
+		return err
+	}
+
+	*msg = Message{
+		ID:            env.Id,
+		CorrelationID: env.CorrelationId,
+		CausationID:   env.CausationId,
+		Type:          env.Type,
+		SchemaVersion: int(env.SchemaVersion),
+		Source:        env.Source,
+		Timestamp:     time.Unix(0, env.TimestampUnixNano),
+		TraceParent:   env.Traceparent,
+		TraceState:    env.Tracestate,
+		Attempt:       int(env.Attempt),
+		Retry:         retryFromProto(env.Retry),
+	}
+
+	switch body := env.Body.(type) {
+	case *sagav1.Envelope_JsonPayload:
+		msg.Payload = body.JsonPayload
+	case *sagav1.Envelope_Payload:
+		t, ok := protoPayload(env.Type)
+		if !ok {
+			return fmt.Errorf("no protobuf payload registered for %s", env.Type)
+		}
+
+		payload := t.New().Interface()
+		if err := proto.Unmarshal(body.Payload, payload); err != nil {
+			return fmt.Errorf("unmarshal %s payload: %w", env.Type, err)
+		}
+
+		raw, err := (protojson.MarshalOptions{UseProtoNames: true}).Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("convert %s payload to JSON: %w", env.Type, err)
+		}
+		msg.Payload = raw
+	}
+
+	return nil
+}
+
+func isNullJSON(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) == 0 || bytes.Equal(raw, []byte("null"))
+}
+
+func retryToProto(p *RetryPolicy) *sagav1.RetryPolicy {
+	if p == nil {
+		return nil
+	}
+
+	kinds := make([]string, 0, len(p.RetryableKinds))
+	for _, kind := range p.RetryableKinds {
+		kinds = append(kinds, string(kind))
+	}
+
+	return &sagav1.RetryPolicy{
+		MaxAttempts:      int32(p.MaxAttempts),
+		InitialBackoffMs: p.InitialBackoff.Milliseconds(),
+		MaxBackoffMs:     p.MaxBackoff.Milliseconds(),
+		Multiplier:       p.Multiplier,
+		RetryableKinds:   kinds,
+	}
+}
+
+func retryFromProto(p *sagav1.RetryPolicy) *RetryPolicy {
+	if p == nil {
+		return nil
+	}
+
+	kinds := make([]ErrorKind, 0, len(p.RetryableKinds))
+	for _, kind := range p.RetryableKinds {
+		kinds = append(kinds, ErrorKind(kind))
+	}
+
+	return &RetryPolicy{
+		MaxAttempts:    int(p.MaxAttempts),
+		InitialBackoff: time.Duration(p.InitialBackoffMs) * time.Millisecond,
+		MaxBackoff:     time.Duration(p.MaxBackoffMs) * time.Millisecond,
+		Multiplier:     p.Multiplier,
+		RetryableKinds: kinds,
+	}
+}
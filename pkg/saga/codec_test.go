@@ -0,0 +1,113 @@
+package saga_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	sagav1 "soa-video-streaming/pkg/pb/saga"
+	"soa-video-streaming/pkg/saga"
+)
+
+const codecTestEvent = "codec_test.bucket_created"
+
+func init() {
+	saga.RegisterProtoPayload(codecTestEvent, &sagav1.Bucket{})
+}
+
+func codecTestMessage(t *testing.T, msgType string, payload any) *saga.Message {
+	t.Helper()
+
+	msg, err := saga.NewSagaMessage("saga-1", msgType, payload, saga.WithSource("content-service"))
+	if err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+	msg.CausationID = "msg-0"
+	msg.TraceState = "vendor=1"
+	msg.Attempt = 2
+	msg.Retry = &saga.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Multiplier:     1.5,
+		RetryableKinds: []saga.ErrorKind{saga.ErrorKindTransient},
+	}
+
+	return msg
+}
+
+func assertSameMessage(t *testing.T, got, want *saga.Message) {
+	t.Helper()
+
+	if !got.Timestamp.Equal(want.Timestamp) {
+		t.Errorf("timestamp %s, want %s", got.Timestamp, want.Timestamp)
+	}
+
+	var gotPayload, wantPayload any
+	_ = json.Unmarshal(got.Payload, &gotPayload)
+	_ = json.Unmarshal(want.Payload, &wantPayload)
+	if !reflect.DeepEqual(gotPayload, wantPayload) {
+		t.Errorf("payload %s, want %s", got.Payload, want.Payload)
+	}
+
+	g, w := *got, *want
+	g.Timestamp, w.Timestamp = time.Time{}, time.Time{}
+	g.Payload, w.Payload = nil, nil
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("decoded %+v, want %+v", g, w)
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	cases := []struct {
+		msgType string
+		payload any
+	}{
+		// Carried by the registered protobuf message
+		{codecTestEvent, map[string]string{"user_id": "u-1", "bucket_name": "u-1-media"}},
+		// Nothing to convert
+		{codecTestEvent, nil},
+		// Travels as JSON inside the envelope
+		{"codec_test.unregistered", map[string]any{"nested": map[string]any{"n": 1.5}}},
+	}
+
+	for _, codec := range []saga.Codec{saga.JSONCodec{}, saga.ProtoCodec{}} {
+		for _, c := range cases {
+			msgType := c.msgType
+			msg := codecTestMessage(t, msgType, c.payload)
+
+			data, err := codec.Marshal(msg)
+			if err != nil {
+				t.Fatalf("%s: marshal %s: %v", codec.ContentType(), msgType, err)
+			}
+
+			var got saga.Message
+			if err := saga.CodecFor(codec.ContentType()).Unmarshal(data, &got); err != nil {
+				t.Fatalf("%s: unmarshal %s: %v", codec.ContentType(), msgType, err)
+			}
+
+			assertSameMessage(t, &got, msg)
+		}
+	}
+}
+
+func TestProtoCodecRejectsPayloadNotMatchingItsMessage(t *testing.T) {
+	msg := codecTestMessage(t, codecTestEvent, map[string]any{"user_id": 42})
+
+	if _, err := (saga.ProtoCodec{}).Marshal(msg); err == nil {
+		t.Error("expected a payload that does not fit the protobuf message to fail")
+	}
+}
+
+func TestCodecForFallsBackToJSON(t *testing.T) {
+	for _, contentType := range []string{"", "text/plain", saga.ContentTypeJSON} {
+		if got := saga.CodecFor(contentType).ContentType(); got != saga.ContentTypeJSON {
+			t.Errorf("CodecFor(%q) = %s, want JSON", contentType, got)
+		}
+	}
+
+	if got := saga.CodecFor(saga.ContentTypeProtobuf).ContentType(); got != saga.ContentTypeProtobuf {
+		t.Errorf("CodecFor(protobuf) = %s", got)
+	}
+}
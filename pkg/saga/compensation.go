@@ -0,0 +1,237 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+type CompensationDefinition struct {
+	Command string `yaml:"command"`
+	Queue   string `yaml:"queue"`
+	Service string `yaml:"service"`
+	// SuccessEvent acknowledges the compensation. Without it the compensation
+	// is fire-and-forget and counts as done once dispatched.
+	SuccessEvent string        `yaml:"success_event"`
+	FailureEvent string        `yaml:"failure_event"`
+	Timeout      time.Duration `yaml:"timeout"`
+	Retry        *RetryPolicy  `yaml:"retry"`
+}
+
+func (w *Workflow) RegisterCompensation(def CompensationDefinition) *Workflow {
+	w.commandDest[def.Command] = CommandDestination{
+		Queue:   def.Queue,
+		Service: def.Service,
+		Timeout: def.Timeout,
+		Retry:   def.Retry,
+	}
+
+	w.compensationCommands[def.Command] = def
+
+	if def.SuccessEvent != "" {
+		w.compensationAcks[def.SuccessEvent] = def.Command
+	}
+
+	if def.FailureEvent != "" {
+		w.compensationFailures[def.FailureEvent] = def.Command
+	}
+
+	return w
+}
+
+func (c *Coordinator) compensate(ctx context.Context, wf *Workflow, state *SagaStateEntity, failedCmd string, attempts int, errMsg string) error {
+	if state.Status.IsTerminal() {
+		return nil
+	}
+
+	if state.Status == SagaStateCompensating {
+		// Already rolling back because of an earlier failure, e.g. a parallel
+		// branch.
+		return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+			if err := c.repo.WithTx(tx).UpdateStep(ctx, state.ID, failedCmd, StepStatusFailed, attempts, errMsg); err != nil {
+				return err
+			}
+
+			return c.settleCompensation(ctx, tx, state)
+		})
+	}
+
+	// Without compensations the saga still ends, once nothing is pending
+	comps := c.compensationsFor(wf, state, failedCmd)
+	if len(comps) == 0 {
+		logrus.WithFields(logrus.Fields{
+			"correlation_id": state.CorrelationID,
+			"cmd":            failedCmd,
+		}).Warn("Saga step failed without compensation")
+	}
+
+	return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		if err := c.repo.WithTx(tx).UpdateStep(ctx, state.ID, failedCmd, StepStatusFailed, attempts, errMsg); err != nil {
+			return err
+		}
+
+		return c.runCompensations(ctx, tx, wf, state, comps)
+	})
+}
+
+// runCompensations dispatches the compensation commands and records each as
+// its own step. The saga stays COMPENSATING until every acknowledged
+// compensation and every outstanding parallel branch has replied.
+func (c *Coordinator) runCompensations(ctx context.Context, tx pgx.Tx, wf *Workflow, state *SagaStateEntity, comps []string) error {
+	repo := c.repo.WithTx(tx)
+
+	state.Status = SagaStateCompensating
+	if err := repo.Update(ctx, state); err != nil {
+		return err
+	}
+
+	if err := c.cancelScheduled(ctx, tx, state); err != nil {
+		return err
+	}
+
+	for _, cmd := range comps {
+		dest, ok := wf.commandDest[cmd]
+		if !ok {
+			return fmt.Errorf("destination not found for compensation: %s", cmd)
+		}
+
+		if err := c.publishOutboxCommand(ctx, tx, HistoryCompensationSent, dest, state.CorrelationID, cmd, state.Data); err != nil {
+			return err
+		}
+
+		step := SagaStep{
+			SagaStateID:  state.ID,
+			StepName:     cmd,
+			ServiceName:  dest.Service,
+			Status:       StepStatusCompleted,
+			Payload:      state.Data,
+			Compensation: true,
+		}
+
+		if wf.compensationCommands[cmd].SuccessEvent != "" {
+			step.Status = StepStatusPending
+			step.DeadlineAt = dest.deadline()
+		}
+
+		if err := repo.AddStep(ctx, step); err != nil {
+			return err
+		}
+	}
+
+	return c.settleCompensation(ctx, tx, state)
+}
+
+// handleLateReply deals with forward replies that arrive while the saga is
+// rolling back. A parallel branch that completes after its sibling failed is
+// undone right away; everything else only updates the step.
+func (c *Coordinator) handleLateReply(ctx context.Context, wf *Workflow, msg *Message, state *SagaStateEntity) error {
+	if cmd, ok := wf.failureToCommand[msg.Type]; ok {
+		var failure FailurePayload
+		if err := json.Unmarshal(msg.Payload, &failure); err != nil {
+			return fmt.Errorf("unmarshal failure payload: %w", err)
+		}
+
+		return c.compensate(ctx, wf, state, cmd, max(msg.Attempt, 1), failure.Error())
+	}
+
+	cmd, ok := wf.eventToCommand[msg.Type]
+	if !ok {
+		return nil
+	}
+
+	// A child saga finished after the parent started rolling back; the
+	// parent only waited for it to leave PENDING.
+	if wf.isChildStep(cmd) {
+		return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+			if err := c.repo.WithTx(tx).UpdateStep(ctx, state.ID, cmd, StepStatusCompleted, max(msg.Attempt, 1), ""); err != nil {
+				return err
+			}
+
+			return c.settleCompensation(ctx, tx, state)
+		})
+	}
+
+	j, ok := wf.branchJoins[cmd]
+	if !ok {
+		return nil
+	}
+
+	branch, _ := j.branch(cmd)
+
+	return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		if err := c.repo.WithTx(tx).UpdateStep(ctx, state.ID, cmd, StepStatusCompleted, max(msg.Attempt, 1), ""); err != nil {
+			return err
+		}
+
+		return c.runCompensations(ctx, tx, wf, state, branch.Compensations)
+	})
+}
+
+// recordCompensationResult marks a compensation step as acknowledged, or as
+// failed when errMsg is set, and settles the saga once nothing is pending.
+func (c *Coordinator) recordCompensationResult(ctx context.Context, state *SagaStateEntity, cmd string, attempts int, errMsg string) error {
+	if state.Status != SagaStateCompensating {
+		return nil
+	}
+
+	return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		repo := c.repo.WithTx(tx)
+
+		if errMsg != "" {
+			logrus.WithFields(logrus.Fields{
+				"correlation_id": state.CorrelationID,
+				"cmd":            cmd,
+				"error":          errMsg,
+			}).Error("Saga compensation failed")
+
+			if err := repo.UpdateStep(ctx, state.ID, cmd, StepStatusFailed, attempts, errMsg); err != nil {
+				return err
+			}
+		} else if err := repo.MarkCompensated(ctx, state.ID, cmd, attempts); err != nil {
+			return err
+		}
+
+		return c.settleCompensation(ctx, tx, state)
+	})
+}
+
+func (c *Coordinator) settleCompensation(ctx context.Context, tx pgx.Tx, state *SagaStateEntity) error {
+	steps, err := c.repo.WithTx(tx).GetSteps(ctx, state.ID)
+	if err != nil {
+		return fmt.Errorf("get steps: %w", err)
+	}
+
+	status := SagaStateCompensated
+	for _, step := range steps {
+		// Wait for outstanding compensations and for parallel branches that
+		// may still need to be undone. The update only bumps the version so
+		// concurrent replies cannot both decide to keep waiting.
+		if step.Status == StepStatusPending {
+			return c.repo.WithTx(tx).Update(ctx, state)
+		}
+
+		if step.Compensation && step.Status == StepStatusFailed {
+			status = SagaStateCompensationFailed
+		}
+	}
+
+	return c.finishCompensation(ctx, tx, state, status)
+}
+
+func (c *Coordinator) finishCompensation(ctx context.Context, tx pgx.Tx, state *SagaStateEntity, status SagaStateStatus) error {
+	state.Status = status
+	if err := c.repo.WithTx(tx).Update(ctx, state); err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"correlation_id": state.CorrelationID,
+		"status":         status,
+	}).Info("Saga compensation finished")
+
+	return c.notifyParent(ctx, tx, state)
+}
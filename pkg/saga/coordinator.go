@@ -3,30 +3,79 @@ package saga
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/oagudo/outbox"
+	"github.com/sirupsen/logrus"
 	"go.uber.org/fx"
 )
 
 func Module() fx.Option {
 	return fx.Options(
 		fx.Provide(NewCoordinator),
+		fx.Invoke(ValidateOnStart),
+		fx.Invoke(RunTimeoutScheduler),
 	)
 }
 
+var (
+	ErrNoCompensation = errors.New("no compensation defined")
+	// ErrConcurrentUpdate means the saga was changed by another event or
+	// orchestrator instance since it was read.
+	ErrConcurrentUpdate = errors.New("saga was updated concurrently")
+)
+
+const maxConflictRetries = 5
+
 type EventHandlerFunc func(ctx context.Context, event *Event) error
 
+// FailureHandlerFunc handles a failure event reported by an actor. The step
+// is already marked FAILED; call Event.Compensate to roll the saga back.
+type FailureHandlerFunc func(ctx context.Context, event *Event, failure *FailurePayload) error
+
 type Repository interface {
-	Create(ctx context.Context, correlationID string, status SagaStateStatus, data json.RawMessage) (*SagaStateEntity, error)
-	Update(ctx context.Context, correlationID string, status SagaStateStatus, data json.RawMessage) error
-	Complete(ctx context.Context, correlationID string) error
+	Create(ctx context.Context, correlationID, workflow string, version int, status SagaStateStatus, data json.RawMessage) (*SagaStateEntity, error)
+	// Update writes status and data only if the stored version still matches
+	// state.Version, and bumps it. It returns ErrConcurrentUpdate otherwise.
+	Update(ctx context.Context, state *SagaStateEntity) error
+	// Complete marks the saga COMPLETED under the same version check as
+	// Update.
+	Complete(ctx context.Context, state *SagaStateEntity) error
 	FindByCorrelationID(ctx context.Context, correlationID string) (*SagaStateEntity, error)
-	AddStep(ctx context.Context, sagaStateID, stepName, serviceName string, status StepStatus) error
-	UpdateStep(ctx context.Context, sagaStateID, stepName string, status StepStatus, errorMessage string) error
+	List(ctx context.Context, filter SagaFilter) ([]SagaStateEntity, error)
+	SetParent(ctx context.Context, sagaStateID, parentCorrelationID string) error
+	// ListChildren returns the child sagas of a saga, oldest first.
+	ListChildren(ctx context.Context, parentCorrelationID string) ([]SagaStateEntity, error)
+	AddStep(ctx context.Context, step SagaStep) error
+	UpdateStep(ctx context.Context, sagaStateID, stepName string, status StepStatus, attempts int, errorMessage string) error
+	ResetStep(ctx context.Context, sagaStateID, stepName string, deadlineAt *time.Time) error
+	MarkCompensated(ctx context.Context, sagaStateID, stepName string, attempts int) error
+	GetSteps(ctx context.Context, sagaStateID string) ([]SagaStep, error)
+	// CancelScheduledSteps deletes the outbox messages of scheduled steps
+	// that were not published yet, marks those steps CANCELLED and returns
+	// them.
+	CancelScheduledSteps(ctx context.Context, sagaStateID string) ([]SagaStep, error)
+	// FindExpiredSteps returns PENDING steps past their deadline, of sagas
+	// that are still STARTED or COMPENSATING.
+	FindExpiredSteps(ctx context.Context, now time.Time, limit int) ([]ExpiredStep, error)
+	StartJoin(ctx context.Context, sagaStateID, join string, branches []string) error
+	// CompleteJoinBranch moves the branch from pending to completed and returns
+	// how many branches are still pending. ok is false when the branch was not
+	// pending.
+	CompleteJoinBranch(ctx context.Context, sagaStateID, join, branch string) (remaining int, ok bool, err error)
+	AwaitSignal(ctx context.Context, sagaStateID, name string, signal SignalState) error
+	// TakeSignal stops waiting for the signal. ok is false when the saga was
+	// not waiting for it, e.g. because a concurrent delivery took it first.
+	TakeSignal(ctx context.Context, sagaStateID, name string) (ok bool, err error)
+	// FindExpiredSignals returns the expired signals of running sagas.
+	FindExpiredSignals(ctx context.Context, now time.Time, limit int) ([]ExpiredSignal, error)
+	AppendHistory(ctx context.Context, entry HistoryEntry) error
+	GetHistory(ctx context.Context, correlationID string) ([]HistoryEntry, error)
 	WithTx(tx pgx.Tx) Repository
 }
 
@@ -42,148 +91,297 @@ type OutboxRepository interface {
 type CommandDestination struct {
 	Queue   string
 	Service string
+	Timeout time.Duration
+	Retry   *RetryPolicy
 }
 
-type StepDefinition struct {
-	Command       string
-	Queue         string
-	Service       string
-	SuccessEvent  string
-	Compensations []string
+func (d CommandDestination) deadline() *time.Time {
+	return d.deadlineFrom(time.Now())
+}
+
+// deadlineFrom returns when a reply to a command delivered at start is due.
+func (d CommandDestination) deadlineFrom(start time.Time) *time.Time {
+	if d.Timeout <= 0 {
+		return nil
+	}
+
+	deadline := start.Add(d.Timeout)
+	return &deadline
 }
 
 type Coordinator struct {
-	repo           Repository
-	tm             TransactionManager
-	outboxRepo     OutboxRepository
-	eventHandlers  map[string]EventHandlerFunc
-	commandDest    map[string]CommandDestination
-	eventToCommand map[string]string
-	compensations  map[string][]string
+	repo        Repository
+	tm          TransactionManager
+	outboxRepo  OutboxRepository
+	workflows   map[workflowKey]*Workflow
+	startEvents map[string]*Workflow
+	schemas     *SchemaRegistry
+	eventQueue  string
 }
 
 func NewCoordinator(repo Repository, tm TransactionManager, outboxRepo OutboxRepository) *Coordinator {
 	return &Coordinator{
-		repo:           repo,
-		tm:             tm,
-		outboxRepo:     outboxRepo,
-		eventHandlers:  make(map[string]EventHandlerFunc),
-		commandDest:    make(map[string]CommandDestination),
-		eventToCommand: make(map[string]string),
-		compensations:  make(map[string][]string),
+		repo:        repo,
+		tm:          tm,
+		outboxRepo:  outboxRepo,
+		workflows:   make(map[workflowKey]*Workflow),
+		startEvents: make(map[string]*Workflow),
 	}
 }
 
-func (c *Coordinator) RegisterStep(step StepDefinition) *Coordinator {
-	c.commandDest[step.Command] = CommandDestination{
-		Queue:   step.Queue,
-		Service: step.Service,
-	}
+// UseSchemas validates incoming events against the registry before any
+// handler runs.
+func (c *Coordinator) UseSchemas(schemas *SchemaRegistry) {
+	c.schemas = schemas
+}
 
-	if step.SuccessEvent != "" {
-		c.eventToCommand[step.SuccessEvent] = step.Command
-	}
+func (c *Coordinator) HandleEvent(ctx context.Context, msg *Message) error {
+	ctx = ContextWithMessage(ctx, msg)
 
-	if len(step.Compensations) > 0 {
-		c.compensations[step.Command] = step.Compensations
+	if err := c.schemas.Validate(msg); err != nil {
+		c.recordRejected(ctx, msg, err)
+		return err
 	}
 
-	return c
+	return retryOnConflict(ctx, func() error {
+		return c.handleEvent(ctx, msg)
+	})
 }
 
-func (c *Coordinator) RegisterCompensationQueue(cmd string, queue string) *Coordinator {
-	c.commandDest[cmd] = CommandDestination{
-		Queue: queue,
+func (c *Coordinator) handleEvent(ctx context.Context, msg *Message) error {
+	state, err := c.GetOrCreateState(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("get/create state: %w", err)
 	}
 
-	return c
-}
+	// No saga to continue and no workflow starts on this event
+	if state == nil {
+		return nil
+	}
 
-func (c *Coordinator) On(event string, handler EventHandlerFunc) *Coordinator {
-	c.eventHandlers[event] = handler
-	return c
-}
+	c.recordReceived(ctx, HistoryEventReceived, msg)
 
-func (c *Coordinator) HandleEvent(ctx context.Context, msg *Message) error {
-	state, err := c.GetOrCreateState(ctx, msg)
+	// Ігноруємо, якщо сага вже завершена або компенсована
+	if state.Status.IsTerminal() {
+		return nil
+	}
+
+	wf, err := c.workflowFor(state)
 	if err != nil {
-		return fmt.Errorf("get/create state: %w", err)
+		return err
 	}
 
-	// Ігноруємо, якщо сага вже завершена або компенсована
-	if state.Status == SagaStateCompleted || state.Status == SagaStateCompensated {
+	if cmd, ok := wf.compensationAcks[msg.Type]; ok {
+		return c.recordCompensationResult(ctx, state, cmd, max(msg.Attempt, 1), "")
+	}
+
+	if cmd, ok := wf.compensationFailures[msg.Type]; ok {
+		var failure FailurePayload
+		if err := json.Unmarshal(msg.Payload, &failure); err != nil {
+			return fmt.Errorf("unmarshal failure payload: %w", err)
+		}
+
+		return c.recordCompensationResult(ctx, state, cmd, max(msg.Attempt, 1), failure.Error())
+	}
+
+	if state.Status == SagaStateCompensating {
+		return c.handleLateReply(ctx, wf, msg, state)
+	}
+
+	// Signals are only accepted through Coordinator.Signal
+	if _, ok := wf.signals[msg.Type]; ok {
 		return nil
 	}
 
-	handler, exists := c.eventHandlers[msg.Type]
-	if !exists {
+	if cmd, ok := wf.failureToCommand[msg.Type]; ok {
+		return c.handleFailureEvent(ctx, wf, msg, state, cmd)
+	}
+
+	cmd, isStep := wf.eventToCommand[msg.Type]
+	j, isBranch := wf.branchJoins[cmd]
+
+	handler, exists := wf.eventHandlers[msg.Type]
+	if !exists && !(isStep && isBranch) {
 		return nil
 	}
 
 	event := &Event{
 		ctx:         ctx,
 		coordinator: c,
+		workflow:    wf,
 		message:     msg,
 		sagaState:   state,
 	}
 
 	return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
-		if cmd, ok := c.eventToCommand[msg.Type]; ok {
-			if err := c.repo.WithTx(tx).UpdateStep(ctx, state.ID, cmd, StepStatusCompleted, ""); err != nil {
+		if isStep {
+			if err := c.repo.WithTx(tx).UpdateStep(ctx, state.ID, cmd, StepStatusCompleted, max(msg.Attempt, 1), ""); err != nil {
 				return err
 			}
 		}
 
 		event.tx = tx
 
-		if err := handler(ctx, event); err != nil {
+		if exists {
+			if err := handler(ctx, event); err != nil {
+				return err
+			}
+		}
+
+		if isStep && isBranch {
+			if err := c.completeBranch(ctx, tx, j, cmd, event); err != nil {
+				return err
+			}
+		}
+
+		return c.repo.WithTx(tx).Update(ctx, state)
+	})
+}
+
+func (c *Coordinator) handleFailureEvent(ctx context.Context, wf *Workflow, msg *Message, state *SagaStateEntity, cmd string) error {
+	var failure FailurePayload
+	if err := json.Unmarshal(msg.Payload, &failure); err != nil {
+		return fmt.Errorf("unmarshal failure payload: %w", err)
+	}
+
+	handler, ok := wf.failureHandlers[msg.Type]
+	if !ok {
+		handler = compensateOnFailure
+	}
+
+	event := &Event{
+		ctx:           ctx,
+		coordinator:   c,
+		workflow:      wf,
+		message:       msg,
+		sagaState:     state,
+		failedCommand: cmd,
+	}
+
+	return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		if err := c.repo.WithTx(tx).UpdateStep(ctx, state.ID, cmd, StepStatusFailed, max(msg.Attempt, 1), failure.Error()); err != nil {
 			return err
 		}
 
-		return c.repo.WithTx(tx).Update(ctx, state.CorrelationID, state.Status, state.Data)
+		event.tx = tx
+
+		if err := handler(ctx, event, &failure); err != nil {
+			return err
+		}
+
+		return c.repo.WithTx(tx).Update(ctx, state)
 	})
 }
 
+// compensateOnFailure rolls the saga back. When the failed step has nothing
+// to compensate, the saga still ends once nothing is pending.
+func compensateOnFailure(ctx context.Context, event *Event, failure *FailurePayload) error {
+	err := event.Compensate()
+	if !errors.Is(err, ErrNoCompensation) {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"correlation_id": event.CorrelationID(),
+		"cmd":            failure.Command,
+	}).Warn("Saga step failed without compensation")
+
+	return event.coordinator.runCompensations(ctx, event.tx, event.workflow, event.sagaState, nil)
+}
+
 func (c *Coordinator) HandleFailure(ctx context.Context, msg *Message) error {
+	ctx = ContextWithMessage(ctx, msg)
+
+	return retryOnConflict(ctx, func() error {
+		return c.handleFailure(ctx, msg)
+	})
+}
+
+func (c *Coordinator) handleFailure(ctx context.Context, msg *Message) error {
 	state, err := c.repo.FindByCorrelationID(ctx, msg.CorrelationID)
 	if err != nil || state == nil {
 		return fmt.Errorf("state not found for failure handling: %w", err)
 	}
 
-	if state.Status == SagaStateCompleted || state.Status == SagaStateCompensated {
-		return nil
+	c.recordReceived(ctx, HistoryCommandFailed, msg)
+
+	wf, err := c.workflowFor(state)
+	if err != nil {
+		return err
 	}
 
-	comps, ok := c.compensations[msg.Type]
-	if !ok {
-		return fmt.Errorf("no compensation defined for failed command: %s", msg.Type)
+	if _, ok := wf.compensationCommands[msg.Type]; ok {
+		return c.recordCompensationResult(ctx, state, msg.Type, max(msg.Attempt, 1), "Compensation failed and moved to DLQ")
 	}
 
-	return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
-		errMsg := "Command failed and moved to DLQ"
-		if err := c.repo.WithTx(tx).UpdateStep(ctx, state.ID, msg.Type, StepStatusFailed, errMsg); err != nil {
-			return err
-		}
+	return c.compensate(ctx, wf, state, msg.Type, max(msg.Attempt, 1), "Command failed and moved to DLQ")
+}
+
+func (c *Coordinator) HandleTimeout(ctx context.Context, step ExpiredStep) error {
+	return retryOnConflict(ctx, func() error {
+		return c.handleTimeout(ctx, step)
+	})
+}
+
+func (c *Coordinator) handleTimeout(ctx context.Context, step ExpiredStep) error {
+	state, err := c.repo.FindByCorrelationID(ctx, step.CorrelationID)
+	if err != nil || state == nil {
+		return fmt.Errorf("state not found for timeout handling: %w", err)
+	}
+
+	wf, err := c.workflowFor(state)
+	if err != nil {
+		return err
+	}
+
+	errMsg := fmt.Sprintf("Step timed out at %s", step.DeadlineAt.Format(time.RFC3339))
+
+	// The saga ended after the step was picked up; nothing waits for it
+	if state.Status.IsTerminal() {
+		logrus.WithFields(logrus.Fields{
+			"correlation_id": step.CorrelationID,
+			"step":           step.StepName,
+			"status":         state.Status,
+		}).Info("Step expired after saga ended, cancelling it")
+
+		return c.repo.UpdateStep(ctx, state.ID, step.StepName, StepStatusCancelled, 0, errMsg)
+	}
+
+	c.recordReceived(ctx, HistoryStepTimedOut, &Message{
+		ID:            deadlineMessageID(state.ID, step.StepName, *step.DeadlineAt),
+		CorrelationID: step.CorrelationID,
+		Type:          step.StepName,
+	})
+
+	if step.Compensation {
+		return c.recordCompensationResult(ctx, state, step.StepName, 0, errMsg)
+	}
 
-		if err := c.repo.WithTx(tx).Update(ctx, state.CorrelationID, SagaStateCompensating, state.Data); err != nil {
+	return c.compensate(ctx, wf, state, step.StepName, 0, errMsg)
+}
+
+// retryOnConflict re-runs fn, which must reload saga state, when it lost a
+// race against a concurrent update of the same saga.
+func retryOnConflict(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !errors.Is(err, ErrConcurrentUpdate) || attempt >= maxConflictRetries {
 			return err
 		}
 
-		for _, cmd := range comps {
-			dest, ok := c.commandDest[cmd]
-			if !ok {
-				continue
-			}
+		logrus.WithField("attempt", attempt).Debug("Saga update conflict, retrying")
 
-			if err := c.publishOutboxCommand(ctx, tx, dest.Queue, state.CorrelationID, cmd, state.Data); err != nil {
-				return err
-			}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(rand.IntN(attempt*20)+1) * time.Millisecond):
 		}
-
-		return c.repo.WithTx(tx).Update(ctx, state.CorrelationID, SagaStateCompensated, state.Data)
-	})
+	}
 }
 
+// GetOrCreateState returns the saga the message belongs to. A new saga is
+// only created when the message is a start event of a registered workflow;
+// otherwise nil is returned.
 func (c *Coordinator) GetOrCreateState(ctx context.Context, msg *Message) (*SagaStateEntity, error) {
 	state, err := c.repo.FindByCorrelationID(ctx, msg.CorrelationID)
 	if err != nil {
@@ -192,49 +390,146 @@ func (c *Coordinator) GetOrCreateState(ctx context.Context, msg *Message) (*Saga
 	if state != nil {
 		return state, nil
 	}
-	return c.repo.Create(ctx, msg.CorrelationID, SagaStateStarted, nil)
+
+	wf, ok := c.startEvents[msg.Type]
+	if !ok {
+		return nil, nil
+	}
+
+	return c.repo.Create(ctx, msg.CorrelationID, wf.name, wf.version, SagaStateStarted, nil)
+}
+
+func (c *Coordinator) publishOutboxCommand(ctx context.Context, tx pgx.Tx, kind HistoryKind, dest CommandDestination, correlationID, cmdType string, payload any) error {
+	_, err := c.saveOutboxCommand(ctx, tx, kind, dest, correlationID, cmdType, payload, time.Now())
+	return err
 }
 
-func (c *Coordinator) publishOutboxCommand(ctx context.Context, tx pgx.Tx, queueName, correlationID, cmdType string, payload any) error {
-	msg, err := NewSagaMessage(correlationID, cmdType, payload)
+// saveOutboxCommand stores the command in the outbox, to be published once
+// scheduledAt has passed, and returns the ID of the outbox message.
+func (c *Coordinator) saveOutboxCommand(ctx context.Context, tx pgx.Tx, kind HistoryKind, dest CommandDestination, correlationID, cmdType string, payload any, scheduledAt time.Time) (uuid.UUID, error) {
+	cause, _ := MessageFromContext(ctx)
+
+	msg, err := NewSagaMessage(correlationID, cmdType, payload, WithSource(CoordinatorSource), WithCause(cause))
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
-	payloadJSON, _ := json.Marshal(msg)
+	msg.Retry = dest.Retry
 
-	return c.outboxRepo.WithTx(tx).Save(ctx, outbox.NewMessage(payloadJSON,
-		outbox.WithID(uuid.New()),
+	id, err := c.saveOutbox(ctx, tx, dest.Queue, msg, scheduledAt)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = c.repo.WithTx(tx).AppendHistory(ctx, HistoryEntry{
+		CorrelationID: correlationID,
+		Kind:          kind,
+		Type:          cmdType,
+		MessageID:     msg.ID,
+		Payload:       msg.Payload,
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (c *Coordinator) saveOutbox(ctx context.Context, tx pgx.Tx, queue string, msg *Message, scheduledAt time.Time) (uuid.UUID, error) {
+	payloadJSON, err := json.Marshal(msg)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("marshal %s: %w", msg.Type, err)
+	}
+
+	id := uuid.New()
+	err = c.outboxRepo.WithTx(tx).Save(ctx, outbox.NewMessage(payloadJSON,
+		outbox.WithID(id),
 		outbox.WithCreatedAt(time.Now()),
-		outbox.WithMetadata([]byte(queueName)),
+		outbox.WithScheduledAt(scheduledAt),
+		outbox.WithMetadata([]byte(queue)),
 	))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
 }
 
 type Event struct {
-	ctx         context.Context
-	coordinator *Coordinator
-	message     *Message
-	sagaState   *SagaStateEntity
-	tx          pgx.Tx
+	ctx           context.Context
+	coordinator   *Coordinator
+	workflow      *Workflow
+	message       *Message
+	sagaState     *SagaStateEntity
+	tx            pgx.Tx
+	failedCommand string
 }
 
 func (e *Event) SendCommand(cmdType string, payload any) error {
-	dest, ok := e.coordinator.commandDest[cmdType]
+	return e.sendCommand(cmdType, payload, nil)
+}
+
+// sendCommand records the command as a pending step. A command scheduled for
+// later stays in the outbox until at and its timeout counts from then.
+func (e *Event) sendCommand(cmdType string, payload any, at *time.Time) error {
+	dest, ok := e.workflow.commandDest[cmdType]
 	if !ok {
 		return fmt.Errorf("destination not found for: %s", cmdType)
 	}
-	if err := e.coordinator.publishOutboxCommand(e.ctx, e.tx, dest.Queue, e.message.CorrelationID, cmdType, payload); err != nil {
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal command payload: %w", err)
+	}
+
+	kind, scheduledAt := HistoryCommandSent, time.Now()
+	if at != nil {
+		kind, scheduledAt = HistoryCommandScheduled, *at
+	}
+
+	id, err := e.coordinator.saveOutboxCommand(e.ctx, e.tx, kind, dest, e.message.CorrelationID, cmdType, json.RawMessage(raw), scheduledAt)
+	if err != nil {
 		return err
 	}
-	return e.coordinator.repo.WithTx(e.tx).AddStep(e.ctx, e.sagaState.ID, cmdType, dest.Service, StepStatusPending)
+
+	step := SagaStep{
+		SagaStateID: e.sagaState.ID,
+		StepName:    cmdType,
+		ServiceName: dest.Service,
+		Status:      StepStatusPending,
+		Payload:     raw,
+		DeadlineAt:  dest.deadlineFrom(scheduledAt),
+	}
+	if at != nil {
+		step.ScheduledAt = at
+		step.OutboxID = id.String()
+	}
+
+	return e.coordinator.repo.WithTx(e.tx).AddStep(e.ctx, step)
 }
 
+// Complete finishes the saga and cancels its scheduled commands that are
+// not due yet.
 func (e *Event) Complete() error {
-	if err := e.coordinator.repo.WithTx(e.tx).Complete(e.ctx, e.message.CorrelationID); err != nil {
+	if err := e.coordinator.repo.WithTx(e.tx).Complete(e.ctx, e.sagaState); err != nil {
 		return err
 	}
 
-	e.sagaState.Status = SagaStateCompleted
-	return nil
+	if err := e.coordinator.cancelScheduled(e.ctx, e.tx, e.sagaState); err != nil {
+		return err
+	}
+
+	return e.coordinator.notifyParent(e.ctx, e.tx, e.sagaState)
+}
+
+// Compensate runs the compensations of the failed command within the event's
+// transaction. It is only meaningful inside a FailureHandlerFunc.
+func (e *Event) Compensate() error {
+	comps := e.coordinator.compensationsFor(e.workflow, e.sagaState, e.failedCommand)
+	if len(comps) == 0 && !e.workflow.isBranch(e.failedCommand) {
+		return fmt.Errorf("%w for failed command: %s", ErrNoCompensation, e.failedCommand)
+	}
+
+	return e.coordinator.runCompensations(e.ctx, e.tx, e.workflow, e.sagaState, comps)
 }
 
 func (e *Event) SetState(state any) error {
@@ -268,3 +563,25 @@ func (e *Event) UnmarshalPayload(target any) error {
 func (e *Event) CorrelationID() string {
 	return e.message.CorrelationID
 }
+
+func (e *Event) MessageID() string {
+	return e.message.ID
+}
+
+// CausationID returns the ID of the command that produced the event, if the
+// sender recorded it.
+func (e *Event) CausationID() string {
+	return e.message.CausationID
+}
+
+func (e *Event) SchemaVersion() int {
+	return e.message.SchemaVersion
+}
+
+func (e *Event) Source() string {
+	return e.message.Source
+}
+
+func (e *Event) TraceParent() string {
+	return e.message.TraceParent
+}
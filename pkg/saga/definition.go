@@ -0,0 +1,488 @@
+package saga
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Definition is a declarative workflow, usually loaded from YAML.
+//
+// The payload of a start event becomes the saga state and the payload of every
+// success event is merged into it. Steps are sent once the event named in
+// After arrives, with a payload built from saga state. Compensations receive
+// the whole saga state. Signals are awaited once their After event arrives and
+// continue the saga like success events. Children start child sagas the same
+// way; the state of a completed child is merged into the parent's state.
+type Definition struct {
+	Name          string                   `yaml:"name"`
+	Version       int                      `yaml:"version"`
+	StartOn       []string                 `yaml:"start_on"`
+	CompleteOn    []string                 `yaml:"complete_on"`
+	Steps         []StepSpec               `yaml:"steps"`
+	Signals       []SignalSpec             `yaml:"signals"`
+	Children      []ChildSpec              `yaml:"children"`
+	Compensations []CompensationDefinition `yaml:"compensations"`
+}
+
+type ChildSpec struct {
+	Workflow      string            `yaml:"workflow"`
+	StartEvent    string            `yaml:"start_event"`
+	After         string            `yaml:"after"`
+	Timeout       time.Duration     `yaml:"timeout"`
+	Compensations []string          `yaml:"compensations"`
+	Payload       map[string]string `yaml:"payload"`
+}
+
+type SignalSpec struct {
+	Name          string        `yaml:"name"`
+	After         string        `yaml:"after"`
+	Timeout       time.Duration `yaml:"timeout"`
+	Compensations []string      `yaml:"compensations"`
+}
+
+type StepSpec struct {
+	Command      string        `yaml:"command"`
+	Service      string        `yaml:"service"`
+	Queue        string        `yaml:"queue"`
+	After        string        `yaml:"after"`
+	SuccessEvent string        `yaml:"success_event"`
+	FailureEvent string        `yaml:"failure_event"`
+	Timeout      time.Duration `yaml:"timeout"`
+	// Delay holds the command in the outbox for this long after the After
+	// event. Pending delayed commands are cancelled when the saga ends.
+	Delay         time.Duration `yaml:"delay"`
+	Retry         *RetryPolicy  `yaml:"retry"`
+	Compensations []string      `yaml:"compensations"`
+	// Payload maps command fields to dotted paths in saga state. An empty
+	// mapping sends the whole state.
+	Payload map[string]string `yaml:"payload"`
+}
+
+func ParseDefinition(data []byte) (*Definition, error) {
+	var def Definition
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&def); err != nil {
+		return nil, fmt.Errorf("decode saga definition: %w", err)
+	}
+
+	return &def, nil
+}
+
+// LoadDefinitions parses every file in fsys that matches pattern.
+func LoadDefinitions(fsys fs.FS, pattern string) ([]*Definition, error) {
+	paths, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := make([]*Definition, 0, len(paths))
+	for _, path := range paths {
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+
+		def, err := ParseDefinition(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		defs = append(defs, def)
+	}
+
+	return defs, nil
+}
+
+// Validate reports every problem in the definition at once.
+func (d *Definition) Validate() error {
+	var errs []error
+	addErr := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if d.Name == "" {
+		addErr("name is required")
+	}
+	if d.Version < 1 {
+		addErr("version must be positive")
+	}
+	if len(d.StartOn) == 0 {
+		addErr("start_on must declare at least one event")
+	}
+	if len(d.Steps) == 0 {
+		addErr("at least one step is required")
+	}
+
+	compensations := make(map[string]bool)
+	for i, comp := range d.Compensations {
+		switch {
+		case comp.Command == "":
+			addErr("compensations[%d]: command is required", i)
+		case comp.Queue == "":
+			addErr("compensation %s: queue is required", comp.Command)
+		case compensations[comp.Command]:
+			addErr("compensation %s: declared twice", comp.Command)
+		}
+		compensations[comp.Command] = true
+	}
+
+	events := make(map[string]bool)
+	for _, event := range d.StartOn {
+		events[event] = true
+	}
+
+	commands := make(map[string]bool)
+	successEvents := make(map[string]string)
+	for i, step := range d.Steps {
+		if step.Command == "" {
+			addErr("steps[%d]: command is required", i)
+			continue
+		}
+		if commands[step.Command] {
+			addErr("step %s: declared twice", step.Command)
+		}
+		commands[step.Command] = true
+
+		if step.Queue == "" {
+			addErr("step %s: queue is required", step.Command)
+		}
+		if step.After == "" {
+			addErr("step %s: after is required", step.Command)
+		}
+		if step.Delay < 0 {
+			addErr("step %s: delay must not be negative", step.Command)
+		}
+
+		if step.SuccessEvent != "" {
+			if other, ok := successEvents[step.SuccessEvent]; ok {
+				addErr("step %s: success event %s is already used by %s", step.Command, step.SuccessEvent, other)
+			}
+			successEvents[step.SuccessEvent] = step.Command
+			events[step.SuccessEvent] = true
+		}
+
+		for _, comp := range step.Compensations {
+			if !compensations[comp] {
+				addErr("step %s: unknown compensation %s", step.Command, comp)
+			}
+		}
+
+		for field, path := range step.Payload {
+			if path == "" {
+				addErr("step %s: payload field %s has no state path", step.Command, field)
+			}
+		}
+	}
+
+	for i, signal := range d.Signals {
+		if signal.Name == "" {
+			addErr("signals[%d]: name is required", i)
+			continue
+		}
+		if events[signal.Name] || commands[signal.Name] {
+			addErr("signal %s: name is already used", signal.Name)
+		}
+		events[signal.Name] = true
+
+		if signal.After == "" {
+			addErr("signal %s: after is required", signal.Name)
+		}
+		if signal.Timeout < 0 {
+			addErr("signal %s: timeout must not be negative", signal.Name)
+		}
+
+		for _, comp := range signal.Compensations {
+			if !compensations[comp] {
+				addErr("signal %s: unknown compensation %s", signal.Name, comp)
+			}
+		}
+	}
+
+	children := make(map[string]bool)
+	for i, child := range d.Children {
+		switch {
+		case child.Workflow == "":
+			addErr("children[%d]: workflow is required", i)
+			continue
+		case children[child.Workflow]:
+			addErr("child %s: declared twice", child.Workflow)
+		}
+		children[child.Workflow] = true
+		events[ChildCompletedEvent(child.Workflow)] = true
+
+		if child.StartEvent == "" {
+			addErr("child %s: start_event is required", child.Workflow)
+		}
+		if child.After == "" {
+			addErr("child %s: after is required", child.Workflow)
+		}
+		if child.Timeout < 0 {
+			addErr("child %s: timeout must not be negative", child.Workflow)
+		}
+
+		for _, comp := range child.Compensations {
+			if !compensations[comp] {
+				addErr("child %s: unknown compensation %s", child.Workflow, comp)
+			}
+		}
+
+		for field, path := range child.Payload {
+			if path == "" {
+				addErr("child %s: payload field %s has no state path", child.Workflow, field)
+			}
+		}
+	}
+
+	for _, child := range d.Children {
+		if child.After != "" && !events[child.After] {
+			addErr("child %s: after event %s is not declared by this workflow", child.Workflow, child.After)
+		}
+	}
+
+	for _, step := range d.Steps {
+		if step.After != "" && !events[step.After] {
+			addErr("step %s: after event %s is not declared by this workflow", step.Command, step.After)
+		}
+	}
+
+	for _, signal := range d.Signals {
+		if signal.After != "" && !events[signal.After] {
+			addErr("signal %s: after event %s is not declared by this workflow", signal.Name, signal.After)
+		}
+	}
+
+	if len(d.CompleteOn) == 0 {
+		addErr("complete_on must declare at least one event")
+	}
+	for _, event := range d.CompleteOn {
+		if !events[event] {
+			addErr("complete_on event %s is not declared by this workflow", event)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RegisterDefinition validates the definition and compiles it into a workflow.
+// Hooks run for their event after saga state is updated and before the next
+// steps are sent.
+func (c *Coordinator) RegisterDefinition(def *Definition, hooks map[string]EventHandlerFunc) (*Workflow, error) {
+	if err := def.Validate(); err != nil {
+		return nil, fmt.Errorf("saga definition %s: %w", def.Name, err)
+	}
+
+	events := def.events()
+	for event := range hooks {
+		if !slices.Contains(events, event) {
+			return nil, fmt.Errorf("saga definition %s: hook for unknown event %s", def.Name, event)
+		}
+	}
+
+	wf := c.Workflow(def.Name, def.Version).StartOn(def.StartOn...)
+	wf.completeOn = slices.Clone(def.CompleteOn)
+
+	for _, step := range def.Steps {
+		wf.RegisterStep(StepDefinition{
+			Command:       step.Command,
+			Queue:         step.Queue,
+			Service:       step.Service,
+			SuccessEvent:  step.SuccessEvent,
+			FailureEvent:  step.FailureEvent,
+			Compensations: step.Compensations,
+			Timeout:       step.Timeout,
+			Retry:         step.Retry,
+			After:         step.After,
+		})
+	}
+
+	for _, signal := range def.Signals {
+		wf.RegisterSignal(SignalDefinition{
+			Name:          signal.Name,
+			Timeout:       signal.Timeout,
+			Compensations: signal.Compensations,
+			After:         signal.After,
+		})
+	}
+
+	for _, child := range def.Children {
+		wf.RegisterChild(ChildDefinition{
+			Workflow:      child.Workflow,
+			StartEvent:    child.StartEvent,
+			Compensations: child.Compensations,
+			Timeout:       child.Timeout,
+			After:         child.After,
+		})
+	}
+
+	for _, comp := range def.Compensations {
+		wf.RegisterCompensation(comp)
+	}
+
+	for _, event := range events {
+		wf.On(event, def.eventHandler(event, hooks[event]))
+	}
+
+	return wf, nil
+}
+
+func (d *Definition) events() []string {
+	events := slices.Clone(d.StartOn)
+	for _, step := range d.Steps {
+		if step.SuccessEvent != "" && !slices.Contains(events, step.SuccessEvent) {
+			events = append(events, step.SuccessEvent)
+		}
+	}
+	for _, signal := range d.Signals {
+		events = append(events, signal.Name)
+	}
+	for _, child := range d.Children {
+		events = append(events, ChildCompletedEvent(child.Workflow))
+	}
+
+	return events
+}
+
+func (d *Definition) eventHandler(event string, hook EventHandlerFunc) EventHandlerFunc {
+	var next []StepSpec
+	for _, step := range d.Steps {
+		if step.After == event {
+			next = append(next, step)
+		}
+	}
+
+	var signals []string
+	for _, signal := range d.Signals {
+		if signal.After == event {
+			signals = append(signals, signal.Name)
+		}
+	}
+
+	var children []ChildSpec
+	for _, child := range d.Children {
+		if child.After == event {
+			children = append(children, child)
+		}
+	}
+
+	complete := slices.Contains(d.CompleteOn, event)
+
+	return func(ctx context.Context, e *Event) error {
+		if err := e.mergePayloadIntoState(); err != nil {
+			return err
+		}
+
+		if hook != nil {
+			if err := hook(ctx, e); err != nil {
+				return err
+			}
+		}
+
+		for _, step := range next {
+			payload, err := step.buildPayload(e.sagaState.Data)
+			if err != nil {
+				return fmt.Errorf("step %s: %w", step.Command, err)
+			}
+
+			if step.Delay > 0 {
+				err = e.SendCommandAfter(step.Command, payload, step.Delay)
+			} else {
+				err = e.SendCommand(step.Command, payload)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, signal := range signals {
+			if err := e.AwaitSignal(signal); err != nil {
+				return err
+			}
+		}
+
+		for _, child := range children {
+			payload, err := StepSpec{Payload: child.Payload}.buildPayload(e.sagaState.Data)
+			if err != nil {
+				return fmt.Errorf("child %s: %w", child.Workflow, err)
+			}
+
+			if _, err := e.StartChild(child.Workflow, payload); err != nil {
+				return err
+			}
+		}
+
+		if complete {
+			return e.Complete()
+		}
+
+		return nil
+	}
+}
+
+func (e *Event) mergePayloadIntoState() error {
+	state := make(map[string]json.RawMessage)
+	if err := e.GetState(&state); err != nil {
+		return err
+	}
+
+	var payload map[string]json.RawMessage
+	if err := e.UnmarshalPayload(&payload); err != nil {
+		return err
+	}
+
+	for key, value := range payload {
+		state[key] = value
+	}
+
+	return e.SetState(state)
+}
+
+func (s StepSpec) buildPayload(state json.RawMessage) (any, error) {
+	if len(s.Payload) == 0 {
+		return state, nil
+	}
+
+	var data any
+	if len(state) > 0 {
+		if err := json.Unmarshal(state, &data); err != nil {
+			return nil, fmt.Errorf("unmarshal state: %w", err)
+		}
+	}
+
+	payload := make(map[string]any, len(s.Payload))
+	for field, path := range s.Payload {
+		value, ok := lookupPath(data, path)
+		if !ok {
+			return nil, fmt.Errorf("payload field %s: %s not found in saga state", field, path)
+		}
+		payload[field] = value
+	}
+
+	return payload, nil
+}
+
+func lookupPath(data any, path string) (any, bool) {
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := data.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		if data, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return data, true
+}
@@ -0,0 +1,122 @@
+package saga_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"soa-video-streaming/pkg/saga"
+)
+
+const shippingDefinition = `
+name: shipping
+version: 1
+start_on: [order.paid]
+complete_on: [shipped]
+steps:
+  - command: cmd.ship
+    queue: shipping
+    after: order.paid
+    success_event: shipped
+    retry:
+      max_attempts: 3
+      initial_backoff: 1s
+    compensations: [cmd.refund]
+    payload:
+      order_id: order.id
+compensations:
+  - command: cmd.refund
+    queue: payments
+`
+
+func TestLoadDefinitions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"workflows/shipping.yml": {Data: []byte(shippingDefinition)},
+		"workflows/README.md":    {Data: []byte("not a workflow")},
+	}
+
+	defs, err := saga.LoadDefinitions(fsys, "workflows/*.yml")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if len(defs) != 1 {
+		t.Fatalf("loaded %d definitions, want 1", len(defs))
+	}
+
+	def := defs[0]
+	if err := def.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if step := def.Steps[0]; step.Retry == nil || step.Retry.MaxAttempts != 3 || step.Payload["order_id"] != "order.id" {
+		t.Errorf("step not decoded: %+v", step)
+	}
+}
+
+func TestParseDefinitionRejectsUnknownFields(t *testing.T) {
+	_, err := saga.ParseDefinition([]byte("name: shipping\nstpes: []\n"))
+	if err == nil || !strings.Contains(err.Error(), "stpes") {
+		t.Errorf("got %v, want an error naming the unknown field", err)
+	}
+}
+
+func TestDefinitionValidateReportsEveryProblem(t *testing.T) {
+	def, err := saga.ParseDefinition([]byte(`
+name: shipping
+version: 0
+start_on: [order.paid]
+complete_on: [delivered]
+steps:
+  - command: cmd.ship
+    after: order.created
+    success_event: shipped
+    compensations: [cmd.refund]
+  - command: cmd.ship
+    queue: shipping
+    after: order.paid
+    success_event: shipped
+signals:
+  - name: shipped
+    after: shipped
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	err = def.Validate()
+	if err == nil {
+		t.Fatal("expected the definition to be rejected")
+	}
+
+	want := []string{
+		"version must be positive",
+		"step cmd.ship: queue is required",
+		"step cmd.ship: unknown compensation cmd.refund",
+		"step cmd.ship: declared twice",
+		"success event shipped is already used by cmd.ship",
+		"signal shipped: name is already used",
+		"step cmd.ship: after event order.created is not declared by this workflow",
+		"complete_on event delivered is not declared by this workflow",
+	}
+	for _, problem := range want {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("error does not report %q:\n%v", problem, err)
+		}
+	}
+}
+
+func TestRegisterDefinitionBuildsValidWorkflow(t *testing.T) {
+	def, err := saga.ParseDefinition([]byte(shippingDefinition))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	c := saga.NewCoordinator(nil, nil, nil)
+	if _, err := c.RegisterDefinition(def, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Errorf("compiled workflow is invalid: %v", err)
+	}
+}
@@ -0,0 +1,433 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrWorkflowNotFound      = errors.New("workflow not found")
+	ErrUnknownDiagramFormat  = errors.New("unknown diagram format")
+	diagramStatusClasses     = []StepStatus{StepStatusPending, StepStatusCompleted, StepStatusFailed, StepStatusCancelled}
+	diagramStatusFillColours = map[StepStatus]string{
+		StepStatusPending:   "#fff3cd",
+		StepStatusCompleted: "#d4edda",
+		StepStatusFailed:    "#f8d7da",
+		StepStatusCancelled: "#e2e3e5",
+	}
+)
+
+type DiagramFormat string
+
+const (
+	DiagramMermaid DiagramFormat = "mermaid"
+	DiagramDOT     DiagramFormat = "dot"
+)
+
+// ParseDiagramFormat accepts mermaid, dot and graphviz. An empty format is
+// Mermaid.
+func ParseDiagramFormat(s string) (DiagramFormat, error) {
+	switch strings.ToLower(s) {
+	case "", "mermaid":
+		return DiagramMermaid, nil
+	case "dot", "graphviz":
+		return DiagramDOT, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownDiagramFormat, s)
+}
+
+// Workflows returns every registered workflow ordered by name and version.
+func (c *Coordinator) Workflows() []*Workflow {
+	return slices.SortedFunc(maps.Values(c.workflows), func(a, b *Workflow) int {
+		if a.name != b.name {
+			return strings.Compare(a.name, b.name)
+		}
+		return a.version - b.version
+	})
+}
+
+// WorkflowDiagram draws the steps, events, queues, services and compensation
+// paths of a workflow. Version 0 selects the latest registered version.
+func (c *Coordinator) WorkflowDiagram(name string, version int, format DiagramFormat) (string, error) {
+	var wf *Workflow
+	for _, w := range c.Workflows() {
+		if w.name == name && (version == 0 || w.version == version) {
+			wf = w
+		}
+	}
+
+	if wf == nil {
+		return "", fmt.Errorf("%w: %s", ErrWorkflowNotFound, name)
+	}
+
+	return wf.diagram().render(format)
+}
+
+// SagaDiagram draws the workflow of a saga with the status of its steps.
+func (c *Coordinator) SagaDiagram(ctx context.Context, correlationID string, format DiagramFormat) (string, error) {
+	details, err := c.GetSaga(ctx, correlationID)
+	if err != nil {
+		return "", err
+	}
+
+	wf, err := c.workflowFor(&details.State)
+	if err != nil {
+		return "", err
+	}
+
+	d := wf.diagram()
+	d.title = fmt.Sprintf("%s %s: %s", d.title, correlationID, details.State.Status)
+
+	// Steps are ordered by creation, so the latest attempt wins
+	for _, step := range details.Steps {
+		key := "cmd:" + step.StepName
+		if workflow, ok := strings.CutPrefix(step.StepName, childStepPrefix); ok {
+			key = "child:" + workflow
+		}
+
+		if n, ok := d.index[key]; ok {
+			n.status = step.Status
+		}
+	}
+
+	return d.render(format)
+}
+
+type diagramNodeKind int
+
+const (
+	nodeEvent diagramNodeKind = iota
+	nodeStart
+	nodeEnd
+	nodeCommand
+	nodeCompensation
+	nodeSignal
+	nodeChild
+	nodeJoin
+)
+
+type diagramNode struct {
+	id     string
+	kind   diagramNodeKind
+	label  []string
+	status StepStatus
+}
+
+type diagramEdge struct {
+	from, to *diagramNode
+	label    string
+	dashed   bool
+}
+
+type diagram struct {
+	title string
+	nodes []*diagramNode
+	index map[string]*diagramNode
+	edges []diagramEdge
+}
+
+// node returns the node of key, creating it on first use. A plain event node
+// takes the kind and label of a more specific declaration seen later, such
+// as a signal that steps already wait for.
+func (d *diagram) node(key string, kind diagramNodeKind, label ...string) *diagramNode {
+	if n, ok := d.index[key]; ok {
+		if n.kind == nodeEvent && kind != nodeEvent {
+			n.kind, n.label = kind, label
+		}
+		return n
+	}
+
+	n := &diagramNode{id: fmt.Sprintf("n%d", len(d.nodes)), kind: kind, label: label}
+	d.nodes = append(d.nodes, n)
+	d.index[key] = n
+
+	return n
+}
+
+func (d *diagram) event(name string) *diagramNode {
+	return d.node("event:"+name, nodeEvent, name)
+}
+
+func (d *diagram) edge(from, to *diagramNode, label string, dashed bool) {
+	d.edges = append(d.edges, diagramEdge{from: from, to: to, label: label, dashed: dashed})
+}
+
+func (w *Workflow) diagram() *diagram {
+	d := &diagram{title: w.key().String(), index: make(map[string]*diagramNode)}
+
+	for _, event := range w.startEvents {
+		d.node("event:"+event, nodeStart, event)
+	}
+
+	for _, step := range w.steps {
+		cmd := d.node("cmd:"+step.Command, nodeCommand, step.Command, destinationLabel(w.commandDest[step.Command]))
+
+		if step.After != "" {
+			d.edge(d.event(step.After), cmd, "", false)
+		}
+		if step.SuccessEvent != "" {
+			d.edge(cmd, d.event(step.SuccessEvent), "success", false)
+		}
+		if step.FailureEvent != "" {
+			d.edge(cmd, d.event(step.FailureEvent), "failure", true)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(w.signals)) {
+		signal := w.signals[name]
+
+		label := []string{"signal " + name}
+		if signal.Timeout > 0 {
+			label = append(label, "expires after "+signal.Timeout.String())
+		}
+
+		n := d.node("event:"+name, nodeSignal, label...)
+		if signal.After != "" {
+			d.edge(d.event(signal.After), n, "await", false)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(w.children)) {
+		child := w.children[name]
+
+		n := d.node("child:"+name, nodeChild, "saga "+name, "starts on "+child.StartEvent)
+		if child.After != "" {
+			d.edge(d.event(child.After), n, "", false)
+		}
+		d.edge(n, d.event(ChildCompletedEvent(name)), "completed", false)
+		d.edge(n, d.event(ChildFailedEvent(name)), "failed", true)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(w.joins)) {
+		j := d.node("join:"+name, nodeJoin, "join "+name)
+
+		for _, branch := range w.joins[name].def.Branches {
+			for _, event := range slices.Sorted(maps.Keys(w.eventToCommand)) {
+				if w.eventToCommand[event] == branch.Command {
+					d.edge(d.event(event), j, "", false)
+				}
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(w.compensationCommands)) {
+		comp := w.compensationCommands[name]
+
+		n := d.node("cmd:"+name, nodeCompensation, name, destinationLabel(w.commandDest[name]))
+		if comp.SuccessEvent != "" {
+			d.edge(n, d.event(comp.SuccessEvent), "ack", false)
+		}
+		if comp.FailureEvent != "" {
+			d.edge(n, d.event(comp.FailureEvent), "failure", true)
+		}
+	}
+
+	// Compensation paths start at the step, signal or child that failed
+	for _, step := range slices.Sorted(maps.Keys(w.compensations)) {
+		from, ok := d.index["cmd:"+step]
+		if workflow, isChild := strings.CutPrefix(step, childStepPrefix); isChild {
+			from, ok = d.index["child:"+workflow]
+		} else if _, isSignal := w.signals[step]; isSignal {
+			from, ok = d.index["event:"+step]
+		}
+		if !ok {
+			continue
+		}
+
+		for _, comp := range w.compensations[step] {
+			d.edge(from, d.node("cmd:"+comp, nodeCompensation, comp), "compensate", true)
+		}
+	}
+
+	if len(w.completeOn) > 0 {
+		end := d.node("end", nodeEnd, "completed")
+		for _, event := range w.completeOn {
+			d.edge(d.event(event), end, "", false)
+		}
+	}
+
+	return d
+}
+
+func destinationLabel(dest CommandDestination) string {
+	parts := make([]string, 0, 3)
+	if dest.Service != "" {
+		parts = append(parts, dest.Service)
+	}
+	if dest.Queue != "" {
+		parts = append(parts, dest.Queue)
+	}
+	if dest.Timeout > 0 {
+		parts = append(parts, "timeout "+dest.Timeout.String())
+	}
+
+	return strings.Join(parts, " · ")
+}
+
+func (d *diagram) render(format DiagramFormat) (string, error) {
+	switch format {
+	case DiagramMermaid:
+		return d.mermaid(), nil
+	case DiagramDOT:
+		return d.dot(), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownDiagramFormat, format)
+}
+
+func (d *diagram) mermaid() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "---\ntitle: %s\n---\nflowchart LR\n", d.title)
+
+	for _, n := range d.nodes {
+		open, closing := mermaidShape(n.kind)
+		fmt.Fprintf(&b, "    %s%s\"%s\"%s\n", n.id, open, mermaidEscape(n.label), closing)
+	}
+
+	for _, e := range d.edges {
+		arrow := "-->"
+		if e.dashed {
+			arrow = "-.->"
+		}
+
+		if e.label != "" {
+			fmt.Fprintf(&b, "    %s %s|%s| %s\n", e.from.id, arrow, e.label, e.to.id)
+		} else {
+			fmt.Fprintf(&b, "    %s %s %s\n", e.from.id, arrow, e.to.id)
+		}
+	}
+
+	if !d.hasStatus() {
+		return b.String()
+	}
+
+	for _, status := range diagramStatusClasses {
+		fmt.Fprintf(&b, "    classDef %s fill:%s\n", strings.ToLower(string(status)), diagramStatusFillColours[status])
+	}
+	for _, n := range d.nodes {
+		if n.status != "" {
+			fmt.Fprintf(&b, "    class %s %s\n", n.id, strings.ToLower(string(n.status)))
+		}
+	}
+
+	return b.String()
+}
+
+func mermaidShape(kind diagramNodeKind) (string, string) {
+	switch kind {
+	case nodeStart:
+		return "([", "])"
+	case nodeEnd:
+		return "((", "))"
+	case nodeCommand:
+		return "[", "]"
+	case nodeCompensation:
+		return "[/", "/]"
+	case nodeSignal:
+		return "{{", "}}"
+	case nodeChild:
+		return "[[", "]]"
+	case nodeJoin:
+		return "{", "}"
+	}
+
+	return "(", ")"
+}
+
+func mermaidEscape(label []string) string {
+	lines := make([]string, 0, len(label))
+	for _, line := range label {
+		if line != "" {
+			lines = append(lines, strings.ReplaceAll(line, `"`, "#quot;"))
+		}
+	}
+
+	return strings.Join(lines, "<br/>")
+}
+
+func (d *diagram) dot() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(d.title))
+	b.WriteString("    rankdir=LR;\n")
+	b.WriteString("    node [fontname=\"Helvetica\", fontsize=10];\n")
+	b.WriteString("    edge [fontname=\"Helvetica\", fontsize=9];\n")
+
+	for _, n := range d.nodes {
+		attrs := []string{"label=" + dotQuote(dotLabel(n.label)), "shape=" + dotShape(n.kind)}
+
+		style := make([]string, 0, 2)
+		if n.kind == nodeCompensation {
+			style = append(style, "dashed")
+		}
+		if n.status != "" {
+			style = append(style, "filled")
+			attrs = append(attrs, "fillcolor="+dotQuote(diagramStatusFillColours[n.status]))
+		}
+		if len(style) > 0 {
+			attrs = append(attrs, "style="+dotQuote(strings.Join(style, ",")))
+		}
+
+		fmt.Fprintf(&b, "    %s [%s];\n", n.id, strings.Join(attrs, ", "))
+	}
+
+	for _, e := range d.edges {
+		var attrs []string
+		if e.label != "" {
+			attrs = append(attrs, "label="+dotQuote(e.label))
+		}
+		if e.dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, "    %s -> %s [%s];\n", e.from.id, e.to.id, strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(&b, "    %s -> %s;\n", e.from.id, e.to.id)
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func dotShape(kind diagramNodeKind) string {
+	switch kind {
+	case nodeStart:
+		return "oval, peripheries=2"
+	case nodeEnd:
+		return "doublecircle"
+	case nodeCommand, nodeCompensation:
+		return "box"
+	case nodeSignal:
+		return "hexagon"
+	case nodeChild:
+		return "box3d"
+	case nodeJoin:
+		return "diamond"
+	}
+
+	return "oval"
+}
+
+func dotLabel(label []string) string {
+	lines := slices.DeleteFunc(slices.Clone(label), func(line string) bool { return line == "" })
+	return strings.Join(lines, "\n")
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func (d *diagram) hasStatus() bool {
+	return slices.ContainsFunc(d.nodes, func(n *diagramNode) bool { return n.status != "" })
+}
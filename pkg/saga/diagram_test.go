@@ -0,0 +1,126 @@
+package saga_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+)
+
+func registerShipping(t *testing.T, c *saga.Coordinator) {
+	t.Helper()
+
+	def, err := saga.ParseDefinition([]byte(shippingDefinition))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if _, err := c.RegisterDefinition(def, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+}
+
+func TestWorkflowDiagram(t *testing.T) {
+	c := saga.NewCoordinator(nil, nil, nil)
+	registerShipping(t, c)
+
+	cases := []struct {
+		format saga.DiagramFormat
+		want   string
+	}{
+		{saga.DiagramMermaid, `---
+title: shipping v1
+---
+flowchart LR
+    n0(["order.paid"])
+    n1["cmd.ship<br/>shipping"]
+    n2("shipped")
+    n3[/"cmd.refund<br/>payments"/]
+    n4(("completed"))
+    n0 --> n1
+    n1 -->|success| n2
+    n1 -.->|compensate| n3
+    n2 --> n4
+`},
+		{saga.DiagramDOT, `digraph "shipping v1" {
+    rankdir=LR;
+    node [fontname="Helvetica", fontsize=10];
+    edge [fontname="Helvetica", fontsize=9];
+    n0 [label="order.paid", shape=oval, peripheries=2];
+    n1 [label="cmd.ship\nshipping", shape=box];
+    n2 [label="shipped", shape=oval];
+    n3 [label="cmd.refund\npayments", shape=box, style="dashed"];
+    n4 [label="completed", shape=doublecircle];
+    n0 -> n1;
+    n1 -> n2 [label="success"];
+    n1 -> n3 [label="compensate", style=dashed];
+    n2 -> n4;
+}
+`},
+	}
+
+	for _, tc := range cases {
+		got, err := c.WorkflowDiagram("shipping", 0, tc.format)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+
+		if got != tc.want {
+			t.Errorf("%s diagram:\n%s\nwant:\n%s", tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestWorkflowDiagramErrors(t *testing.T) {
+	c := saga.NewCoordinator(nil, nil, nil)
+	registerShipping(t, c)
+
+	if _, err := c.WorkflowDiagram("shipping", 2, saga.DiagramMermaid); !errors.Is(err, saga.ErrWorkflowNotFound) {
+		t.Errorf("got %v, want ErrWorkflowNotFound", err)
+	}
+
+	if _, err := saga.ParseDiagramFormat("svg"); !errors.Is(err, saga.ErrUnknownDiagramFormat) {
+		t.Errorf("got %v, want ErrUnknownDiagramFormat", err)
+	}
+
+	for input, want := range map[string]saga.DiagramFormat{
+		"":         saga.DiagramMermaid,
+		"Mermaid":  saga.DiagramMermaid,
+		"graphviz": saga.DiagramDOT,
+	} {
+		if got, err := saga.ParseDiagramFormat(input); err != nil || got != want {
+			t.Errorf("ParseDiagramFormat(%q) = %s, %v, want %s", input, got, err, want)
+		}
+	}
+}
+
+func TestSagaDiagramShowsStepStatus(t *testing.T) {
+	h := sagatest.New(t)
+	registerShipping(t, h.Coordinator())
+
+	h.Actor("shipping").Register("cmd.ship", func(_ context.Context, _ *saga.Message) (any, error) {
+		return nil, saga.Permanent(errors.New("address not found"))
+	}, "shipped", "events")
+	h.Actor("payments").Register("cmd.refund", nil, "", "events")
+
+	h.Emit("order.paid", "order-1", map[string]any{"order": map[string]any{"id": "o-1"}})
+	h.AssertStatus("order-1", saga.SagaStateCompensated)
+
+	got, err := h.Coordinator().SagaDiagram(context.Background(), "order-1", saga.DiagramMermaid)
+	if err != nil {
+		t.Fatalf("diagram: %v", err)
+	}
+
+	for _, line := range []string{
+		"title: shipping v1 order-1: COMPENSATED",
+		"class n1 failed",
+		"class n3 completed",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("diagram does not contain %q:\n%s", line, got)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package saga
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// workerQueueSize bounds how many deliveries wait for one worker before the
+// consumer blocks.
+const workerQueueSize = 64
+
+// Dispatcher hashes correlation IDs onto a fixed set of workers. Messages of
+// one saga are handled one at a time in delivery order, while different
+// sagas run in parallel. The consumer feeding it must run a single goroutine,
+// so deliveries reach the dispatcher in queue order.
+type Dispatcher struct {
+	mu      sync.RWMutex
+	closed  bool
+	workers []chan func()
+	done    sync.WaitGroup
+}
+
+func NewDispatcher(workers int) *Dispatcher {
+	d := &Dispatcher{
+		workers: make([]chan func(), max(workers, 1)),
+	}
+
+	for i := range d.workers {
+		d.workers[i] = make(chan func(), workerQueueSize)
+
+		d.done.Add(1)
+		go d.work(d.workers[i])
+	}
+
+	return d
+}
+
+// Dispatch queues job on the worker of correlationID. It returns false once
+// the dispatcher is closed.
+func (d *Dispatcher) Dispatch(correlationID string, job func()) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return false
+	}
+
+	d.workers[d.shard(correlationID)] <- job
+	return true
+}
+
+// Close stops accepting deliveries and waits until the queued ones are
+// handled, or ctx ends.
+func (d *Dispatcher) Close(ctx context.Context) {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, w := range d.workers {
+			close(w)
+		}
+	}
+	d.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		d.done.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		logrus.Warn("Shutdown deadline reached before saga workers finished")
+	}
+}
+
+func (d *Dispatcher) work(jobs <-chan func()) {
+	defer d.done.Done()
+
+	for job := range jobs {
+		job()
+	}
+}
+
+func (d *Dispatcher) shard(correlationID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(correlationID))
+
+	return int(h.Sum32() % uint32(len(d.workers)))
+}
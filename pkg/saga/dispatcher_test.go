@@ -0,0 +1,100 @@
+package saga
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcherKeepsCorrelationOrder(t *testing.T) {
+	d := NewDispatcher(4)
+
+	const sagas, perSaga = 16, 100
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string][]int)
+	)
+
+	for seq := range perSaga {
+		for i := range sagas {
+			cid := fmt.Sprintf("saga-%d", i)
+			d.Dispatch(cid, func() {
+				if rand.IntN(10) == 0 {
+					time.Sleep(time.Millisecond)
+				}
+
+				mu.Lock()
+				seen[cid] = append(seen[cid], seq)
+				mu.Unlock()
+			})
+		}
+	}
+
+	d.Close(context.Background())
+
+	for cid, seqs := range seen {
+		if len(seqs) != perSaga || !slices.IsSorted(seqs) {
+			t.Errorf("saga %s handled out of order: %v", cid, seqs)
+		}
+	}
+}
+
+func TestDispatcherRunsSagasInParallel(t *testing.T) {
+	d := NewDispatcher(2)
+	defer d.Close(context.Background())
+
+	// Two sagas on different workers
+	first, second := "saga-0", ""
+	for i := 1; second == ""; i++ {
+		if cid := fmt.Sprintf("saga-%d", i); d.shard(cid) != d.shard(first) {
+			second = cid
+		}
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	d.Dispatch(first, func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	d.Dispatch(second, func() {
+		close(done)
+	})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("a busy saga held up another saga")
+	}
+	close(release)
+}
+
+func TestDispatcherCloseDrainsQueuedJobs(t *testing.T) {
+	d := NewDispatcher(1)
+
+	var handled []int
+	for i := range 10 {
+		if !d.Dispatch("saga-1", func() { handled = append(handled, i) }) {
+			t.Fatal("dispatch refused before close")
+		}
+	}
+
+	d.Close(context.Background())
+
+	if len(handled) != 10 {
+		t.Errorf("handled %d of 10 queued jobs before close returned", len(handled))
+	}
+
+	if d.Dispatch("saga-1", func() {}) {
+		t.Error("dispatch accepted after close")
+	}
+}
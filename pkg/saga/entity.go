@@ -14,24 +14,47 @@ const (
 	SagaStateCompleted    SagaStateStatus = "COMPLETED"
 	SagaStateCompensating SagaStateStatus = "COMPENSATING"
 	SagaStateCompensated  SagaStateStatus = "COMPENSATED"
+	// SagaStateCompensationFailed means at least one compensation was not
+	// acknowledged and the saga needs manual attention.
+	SagaStateCompensationFailed SagaStateStatus = "COMPENSATION_FAILED"
+	SagaStateAborted            SagaStateStatus = "ABORTED"
 )
 
+func (s SagaStateStatus) IsTerminal() bool {
+	switch s {
+	case SagaStateCompleted, SagaStateCompensated, SagaStateCompensationFailed, SagaStateAborted:
+		return true
+	}
+
+	return false
+}
+
 type StepStatus string
 
 const (
 	StepStatusPending   StepStatus = "PENDING"
 	StepStatusCompleted StepStatus = "COMPLETED"
 	StepStatusFailed    StepStatus = "FAILED"
+	// StepStatusCancelled marks a scheduled command that was dropped before
+	// it was delivered.
+	StepStatusCancelled StepStatus = "CANCELLED"
 )
 
 type SagaStateEntity struct {
-	ID            string
-	CorrelationID string
-	Status        SagaStateStatus
-	Data          json.RawMessage
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	CompletedAt   *time.Time
+	ID              string
+	CorrelationID   string
+	Workflow        string
+	WorkflowVersion int
+	Version         int
+	Status          SagaStateStatus
+	Data            json.RawMessage
+	Joins           map[string]*JoinState
+	Signals         map[string]*SignalState
+	// ParentCorrelationID links a child saga to the saga that started it.
+	ParentCorrelationID string
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	CompletedAt         *time.Time
 }
 
 type SagaStep struct {
@@ -40,21 +63,49 @@ type SagaStep struct {
 	StepName      string
 	ServiceName   string
 	Status        StepStatus
+	Payload       json.RawMessage
 	ExecutedAt    *time.Time
 	CompensatedAt *time.Time
 	ErrorMessage  string
-	CreatedAt     time.Time
+	Attempts      int
+	DeadlineAt    *time.Time
+	Compensation  bool
+	// ScheduledAt and OutboxID are set for commands delivered later through
+	// the outbox.
+	ScheduledAt *time.Time
+	OutboxID    string
+	CreatedAt   time.Time
+}
+
+type ExpiredStep struct {
+	SagaStep
+	CorrelationID string
 }
 
 type Message struct {
-	CorrelationID string          `json:"correlation_id"`
+	ID            string `json:"id,omitempty"`
+	CorrelationID string `json:"correlation_id"`
+	// CausationID is the ID of the message that caused this one.
+	CausationID   string          `json:"causation_id,omitempty"`
 	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schema_version,omitempty"`
+	Source        string          `json:"source,omitempty"`
 	Payload       json.RawMessage `json:"payload"`
 	Timestamp     time.Time       `json:"timestamp"`
+	// TraceParent and TraceState carry the W3C trace context.
+	TraceParent string `json:"traceparent,omitempty"`
+	TraceState  string `json:"tracestate,omitempty"`
+	// Attempt is the 1-based delivery attempt of a command. Replies carry the
+	// attempt of the command that produced them.
+	Attempt int          `json:"attempt,omitempty"`
+	Retry   *RetryPolicy `json:"retry,omitempty"`
 }
 
 type MessageConfig struct {
 	WithAutoCorrelationID bool
+	Source                string
+	SchemaVersion         int
+	Cause                 *Message
 }
 
 func WithAutoCorrelationID() func(m *MessageConfig) {
@@ -63,6 +114,25 @@ func WithAutoCorrelationID() func(m *MessageConfig) {
 	}
 }
 
+func WithSource(source string) func(m *MessageConfig) {
+	return func(m *MessageConfig) {
+		m.Source = source
+	}
+}
+
+func WithSchemaVersion(version int) func(m *MessageConfig) {
+	return func(m *MessageConfig) {
+		m.SchemaVersion = version
+	}
+}
+
+// WithCause sets the causation ID and continues the trace of cause.
+func WithCause(cause *Message) func(m *MessageConfig) {
+	return func(m *MessageConfig) {
+		m.Cause = cause
+	}
+}
+
 func NewSagaMessage(correlationID, msgType string, payload any, opts ...func(m *MessageConfig)) (*Message, error) {
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
@@ -78,10 +148,20 @@ func NewSagaMessage(correlationID, msgType string, payload any, opts ...func(m *
 		correlationID = uuid.NewString()
 	}
 
-	return &Message{
+	if config.SchemaVersion == 0 {
+		config.SchemaVersion = CurrentSchemaVersion
+	}
+
+	msg := &Message{
+		ID:            uuid.NewString(),
 		CorrelationID: correlationID,
 		Type:          msgType,
+		SchemaVersion: config.SchemaVersion,
+		Source:        config.Source,
 		Payload:       payloadBytes,
 		Timestamp:     time.Now(),
-	}, nil
+	}
+	msg.causedBy(config.Cause)
+
+	return msg, nil
 }
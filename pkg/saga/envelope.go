@@ -0,0 +1,172 @@
+package saga
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	gorabbit "github.com/wagslane/go-rabbitmq"
+)
+
+// CurrentSchemaVersion is stamped on messages that do not set their own.
+const CurrentSchemaVersion = 1
+
+// CoordinatorSource is the source of every command the coordinator sends.
+const CoordinatorSource = "saga-coordinator"
+
+// AMQP headers carrying the envelope. Message ID, correlation ID, type and
+// source also go into the standard AMQP properties.
+const (
+	HeaderCausationID   = "x-causation-id"
+	HeaderSchemaVersion = "x-schema-version"
+	HeaderTraceParent   = "traceparent"
+	HeaderTraceState    = "tracestate"
+)
+
+type messageKey struct{}
+
+// ContextWithMessage marks msg as the message being handled, so messages
+// created under ctx record it as their cause and continue its trace.
+func ContextWithMessage(ctx context.Context, msg *Message) context.Context {
+	return context.WithValue(ctx, messageKey{}, msg)
+}
+
+// MessageFromContext returns the message being handled, if any.
+func MessageFromContext(ctx context.Context) (*Message, bool) {
+	msg, ok := ctx.Value(messageKey{}).(*Message)
+	return msg, ok && msg != nil
+}
+
+// causedBy links msg to the message that caused it: the causation ID points
+// at the cause and the trace continues with a new span.
+func (m *Message) causedBy(cause *Message) {
+	if cause == nil {
+		m.TraceParent = newTraceParent("")
+		return
+	}
+
+	m.CausationID = cause.ID
+	m.TraceParent = newTraceParent(cause.TraceParent)
+	m.TraceState = cause.TraceState
+}
+
+// EncodeMessage marshals msg with codec and returns the AMQP properties and
+// headers to publish it with.
+func EncodeMessage(msg *Message, codec Codec) ([]byte, []func(*gorabbit.PublishOptions), error) {
+	body, err := codec.Marshal(msg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode %s: %w", msg.Type, err)
+	}
+
+	return body, msg.publishOptions(codec.ContentType()), nil
+}
+
+func (m *Message) publishOptions(contentType string) []func(*gorabbit.PublishOptions) {
+	headers := gorabbit.Table{
+		HeaderSchemaVersion: int32(m.SchemaVersion),
+	}
+	if m.CausationID != "" {
+		headers[HeaderCausationID] = m.CausationID
+	}
+	if m.TraceParent != "" {
+		headers[HeaderTraceParent] = m.TraceParent
+	}
+	if m.TraceState != "" {
+		headers[HeaderTraceState] = m.TraceState
+	}
+
+	return []func(*gorabbit.PublishOptions){
+		gorabbit.WithPublishOptionsContentType(contentType),
+		gorabbit.WithPublishOptionsMessageID(m.ID),
+		gorabbit.WithPublishOptionsCorrelationID(m.CorrelationID),
+		gorabbit.WithPublishOptionsType(m.Type),
+		gorabbit.WithPublishOptionsAppID(m.Source),
+		gorabbit.WithPublishOptionsTimestamp(m.Timestamp),
+		gorabbit.WithPublishOptionsHeaders(headers),
+	}
+}
+
+// DecodeMessage reads a delivery into a Message with the codec of its
+// content type. Envelope fields missing from the body are taken from the
+// AMQP properties and headers, so producers that only set headers are still
+// traced.
+func DecodeMessage(d gorabbit.Delivery) (*Message, error) {
+	var msg Message
+	if err := CodecFor(d.ContentType).Unmarshal(d.Body, &msg); err != nil {
+		return nil, fmt.Errorf("unmarshal message: %w", err)
+	}
+
+	if msg.ID == "" {
+		msg.ID = d.MessageId
+	}
+	if msg.CorrelationID == "" {
+		msg.CorrelationID = d.CorrelationId
+	}
+	if msg.Type == "" {
+		msg.Type = d.Type
+	}
+	if msg.Source == "" {
+		msg.Source = d.AppId
+	}
+	if msg.CausationID == "" {
+		msg.CausationID = headerString(d.Headers, HeaderCausationID)
+	}
+	if msg.TraceParent == "" {
+		msg.TraceParent = headerString(d.Headers, HeaderTraceParent)
+	}
+	if msg.TraceState == "" {
+		msg.TraceState = headerString(d.Headers, HeaderTraceState)
+	}
+	if msg.SchemaVersion == 0 {
+		msg.SchemaVersion = headerInt(d.Headers, HeaderSchemaVersion)
+	}
+	// Messages from producers that predate the envelope
+	if msg.SchemaVersion == 0 {
+		msg.SchemaVersion = CurrentSchemaVersion
+	}
+
+	return &msg, nil
+}
+
+func headerString(headers amqp.Table, key string) string {
+	s, _ := headers[key].(string)
+	return s
+}
+
+func headerInt(headers amqp.Table, key string) int {
+	switch v := headers[key].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+
+	return 0
+}
+
+// newTraceParent returns a W3C traceparent for a new span. The trace ID and
+// flags of a valid parent are kept; otherwise a new sampled trace starts.
+func newTraceParent(parent string) string {
+	traceID, flags := randomHex(16), "01"
+
+	if parts := strings.Split(parent, "-"); len(parts) == 4 && len(parts[1]) == 32 && len(parts[3]) == 2 {
+		traceID, flags = parts[1], parts[3]
+	}
+
+	return "00-" + traceID + "-" + randomHex(8) + "-" + flags
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
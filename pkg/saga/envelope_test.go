@@ -0,0 +1,95 @@
+package saga_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	gorabbit "github.com/wagslane/go-rabbitmq"
+
+	"soa-video-streaming/pkg/saga"
+)
+
+func TestNewSagaMessageContinuesTraceOfCause(t *testing.T) {
+	cause, err := saga.NewSagaMessage("saga-1", "user.sign_up", nil)
+	if err != nil {
+		t.Fatalf("create cause: %v", err)
+	}
+	cause.TraceState = "vendor=1"
+
+	msg, err := saga.NewSagaMessage("saga-1", "cmd.create_bucket", nil,
+		saga.WithCause(cause), saga.WithSource("orchestrator"))
+	if err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+
+	if msg.CausationID != cause.ID {
+		t.Errorf("causation ID %q, want %q", msg.CausationID, cause.ID)
+	}
+	if msg.Source != "orchestrator" || msg.SchemaVersion != saga.CurrentSchemaVersion {
+		t.Errorf("source %q and schema version %d not stamped", msg.Source, msg.SchemaVersion)
+	}
+	if msg.TraceState != cause.TraceState {
+		t.Errorf("trace state %q, want %q", msg.TraceState, cause.TraceState)
+	}
+
+	parent, child := strings.Split(cause.TraceParent, "-"), strings.Split(msg.TraceParent, "-")
+	if len(child) != 4 || child[1] != parent[1] {
+		t.Errorf("traceparent %q does not continue trace %q", msg.TraceParent, cause.TraceParent)
+	}
+	if child[2] == parent[2] {
+		t.Errorf("traceparent %q reuses the span of its cause", msg.TraceParent)
+	}
+}
+
+func TestDecodeMessageFallsBackToAMQPProperties(t *testing.T) {
+	d := gorabbit.Delivery{Delivery: amqp.Delivery{
+		Body:          []byte(`{"payload":{"user_id":"u-1"}}`),
+		MessageId:     "msg-1",
+		CorrelationId: "saga-1",
+		Type:          "user.sign_up",
+		AppId:         "user-service",
+		Headers: amqp.Table{
+			saga.HeaderCausationID:   "msg-0",
+			saga.HeaderTraceParent:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			saga.HeaderSchemaVersion: int32(2),
+		},
+	}}
+
+	msg, err := saga.DecodeMessage(d)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	want := saga.Message{
+		ID:            "msg-1",
+		CorrelationID: "saga-1",
+		CausationID:   "msg-0",
+		Type:          "user.sign_up",
+		SchemaVersion: 2,
+		Source:        "user-service",
+		TraceParent:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+	got := *msg
+	got.Payload = nil
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeMessageDefaultsSchemaVersion(t *testing.T) {
+	d := gorabbit.Delivery{Delivery: amqp.Delivery{
+		Body: []byte(`{"correlation_id":"saga-1","type":"user.sign_up"}`),
+	}}
+
+	msg, err := saga.DecodeMessage(d)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if msg.SchemaVersion != saga.CurrentSchemaVersion {
+		t.Errorf("schema version %d, want %d", msg.SchemaVersion, saga.CurrentSchemaVersion)
+	}
+}
@@ -0,0 +1,58 @@
+package saga
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+func ModuleEventsController() fx.Option {
+	return fx.Options(
+		fx.Provide(NewEventsController),
+	)
+}
+
+// EventsController feeds events and dead-lettered commands received from a
+// Transport to the coordinator.
+type EventsController struct {
+	coordinator *Coordinator
+}
+
+func NewEventsController(coordinator *Coordinator) *EventsController {
+	return &EventsController{
+		coordinator: coordinator,
+	}
+}
+
+func (c *EventsController) HandleEvent(ctx context.Context, msg *Message) Action {
+	if err := c.coordinator.HandleEvent(ctx, msg); err != nil {
+		return nackFor(ctx, err, "Failed to handle saga event")
+	}
+
+	return Ack
+}
+
+func (c *EventsController) HandleFailure(ctx context.Context, msg *Message) Action {
+	logrus.WithField("correlation_id", msg.CorrelationID).
+		Infof("Processing failure from DLQ for command: %s", msg.Type)
+
+	if err := c.coordinator.HandleFailure(ctx, msg); err != nil {
+		return nackFor(ctx, err, "Failed to compensate saga")
+	}
+
+	return Ack
+}
+
+// nackFor requeues messages whose handling was cut short by shutdown, so
+// another instance picks them up, and rejects the rest.
+func nackFor(ctx context.Context, err error, message string) Action {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		logrus.WithError(err).Warn("Handling interrupted, requeueing message")
+		return Requeue
+	}
+
+	logrus.WithError(err).Error(message)
+	return Reject
+}
@@ -0,0 +1,41 @@
+package saga
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BusinessError is a deliberate, non-retryable failure reported by an actor.
+// It is sent back to the coordinator as a failure event instead of going to
+// the DLQ.
+type BusinessError struct {
+	Code    string
+	Message string
+}
+
+func (e *BusinessError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func Fail(code, message string) error {
+	return &BusinessError{Code: code, Message: message}
+}
+
+func AsBusinessError(err error) (*BusinessError, bool) {
+	var businessErr *BusinessError
+	if errors.As(err, &businessErr) {
+		return businessErr, true
+	}
+
+	return nil, false
+}
+
+type FailurePayload struct {
+	Command string `json:"command" validate:"required"`
+	Code    string `json:"code" validate:"required"`
+	Message string `json:"message"`
+}
+
+func (f *FailurePayload) Error() string {
+	return fmt.Sprintf("[%s] %s", f.Code, f.Message)
+}
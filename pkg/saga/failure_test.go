@@ -0,0 +1,36 @@
+package saga_test
+
+import (
+	"context"
+	"testing"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+)
+
+func TestFailureReplyWithoutCompensationEndsSaga(t *testing.T) {
+	h := sagatest.New(t)
+
+	h.Coordinator().Workflow("shipping", 1).
+		StartOn("order.paid").
+		RegisterStep(saga.StepDefinition{
+			Command:      "cmd.ship",
+			Queue:        "shipping",
+			SuccessEvent: "shipped",
+			FailureEvent: "ship_failed",
+		}).
+		On("order.paid", func(_ context.Context, e *saga.Event) error {
+			return e.SendCommand("cmd.ship", nil)
+		}).
+		On("shipped", func(_ context.Context, e *saga.Event) error {
+			return e.Complete()
+		})
+
+	h.Actor("shipping").Register("cmd.ship", nil, "shipped", "events", saga.WithFailureEvent("ship_failed"))
+	h.FailCommand("cmd.ship", saga.Fail("no_address", "address not found"), 1)
+
+	h.Emit("order.paid", "order-1", nil)
+
+	h.AssertStatus("order-1", saga.SagaStateCompensated).
+		AssertStepStatus("order-1", "cmd.ship", saga.StepStatusFailed)
+}
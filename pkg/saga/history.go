@@ -0,0 +1,111 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type HistoryKind string
+
+const (
+	HistoryEventReceived    HistoryKind = "EVENT_RECEIVED"
+	HistoryCommandSent      HistoryKind = "COMMAND_SENT"
+	HistoryCommandScheduled HistoryKind = "COMMAND_SCHEDULED"
+	HistoryCommandCancelled HistoryKind = "COMMAND_CANCELLED"
+	HistoryCompensationSent HistoryKind = "COMPENSATION_SENT"
+	HistoryCommandFailed    HistoryKind = "COMMAND_FAILED"
+	HistoryStepTimedOut     HistoryKind = "STEP_TIMED_OUT"
+	HistoryEventRejected    HistoryKind = "EVENT_REJECTED"
+	HistorySignalAwaited    HistoryKind = "SIGNAL_AWAITED"
+	HistorySignalReceived   HistoryKind = "SIGNAL_RECEIVED"
+	HistorySignalExpired    HistoryKind = "SIGNAL_EXPIRED"
+	HistoryChildStarted     HistoryKind = "CHILD_STARTED"
+	// HistoryStateChanged entries are written by the database whenever the
+	// saga status or data changes. Type holds the new status and Payload the
+	// saga data at that point.
+	HistoryStateChanged HistoryKind = "STATE_CHANGED"
+)
+
+// HistoryEntry is one record of the append-only saga log.
+type HistoryEntry struct {
+	ID            int64
+	CorrelationID string
+	Kind          HistoryKind
+	Type          string
+	// MessageID deduplicates entries for redelivered messages.
+	MessageID string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// History returns the saga log of a correlation ID in the order it was
+// written.
+func (c *Coordinator) History(ctx context.Context, correlationID string) ([]HistoryEntry, error) {
+	entries, err := c.repo.GetHistory(ctx, correlationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, ErrSagaNotFound
+	}
+
+	return entries, nil
+}
+
+// recordReceived logs a message the saga has seen. It runs outside the
+// handling transaction so the log also shows messages whose handling failed.
+func (c *Coordinator) recordReceived(ctx context.Context, kind HistoryKind, msg *Message) {
+	err := c.repo.AppendHistory(ctx, HistoryEntry{
+		CorrelationID: msg.CorrelationID,
+		Kind:          kind,
+		Type:          msg.Type,
+		MessageID:     msg.ID,
+		Payload:       msg.Payload,
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("correlation_id", msg.CorrelationID).Error("Failed to append saga history")
+	}
+}
+
+// deadlineMessageID identifies a deadline of a saga step or signal. Expiries
+// have no message of their own, and the ID keeps a handler retried after a
+// conflict from logging the same expiry twice.
+func deadlineMessageID(sagaStateID, name string, deadline time.Time) string {
+	return fmt.Sprintf("%s:%s:%d", sagaStateID, name, deadline.UnixNano())
+}
+
+// recordRejected logs an event that failed validation, if it belongs to a
+// known saga. Payload holds the reason next to the rejected payload.
+func (c *Coordinator) recordRejected(ctx context.Context, msg *Message, reason error) {
+	log := logrus.WithError(reason).WithFields(logrus.Fields{
+		"correlation_id": msg.CorrelationID,
+		"event":          msg.Type,
+	})
+	log.Warn("Rejected saga event")
+
+	state, err := c.repo.FindByCorrelationID(ctx, msg.CorrelationID)
+	if err != nil || state == nil {
+		return
+	}
+
+	payload, err := json.Marshal(struct {
+		Reason  string          `json:"reason"`
+		Payload json.RawMessage `json:"payload"`
+	}{reason.Error(), msg.Payload})
+	if err != nil {
+		log.WithError(err).Error("Failed to marshal rejected event")
+		return
+	}
+
+	c.recordReceived(ctx, HistoryEventRejected, &Message{
+		ID:            msg.ID,
+		CorrelationID: msg.CorrelationID,
+		Type:          msg.Type,
+		Payload:       payload,
+	})
+}
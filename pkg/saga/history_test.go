@@ -0,0 +1,133 @@
+package saga_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+)
+
+// conflictingRepository loses the next conflicts saga updates to a
+// concurrent writer.
+type conflictingRepository struct {
+	*sagatest.Repository
+	conflicts int
+}
+
+func (r *conflictingRepository) WithTx(_ pgx.Tx) saga.Repository {
+	return r
+}
+
+func (r *conflictingRepository) Update(ctx context.Context, state *saga.SagaStateEntity) error {
+	if r.conflicts > 0 {
+		r.conflicts--
+		return saga.ErrConcurrentUpdate
+	}
+
+	return r.Repository.Update(ctx, state)
+}
+
+func newConflictingCoordinator() (*saga.Coordinator, *conflictingRepository) {
+	store := sagatest.NewStore()
+	repo := &conflictingRepository{Repository: sagatest.NewRepository(store)}
+	c := saga.NewCoordinator(repo, sagatest.NewTransactionManager(store), sagatest.NewOutboxRepository(store))
+
+	return c, repo
+}
+
+func historyOf(t *testing.T, c *saga.Coordinator, correlationID string, kind saga.HistoryKind) int {
+	t.Helper()
+
+	entries, err := c.History(context.Background(), correlationID)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+
+	n := 0
+	for _, entry := range entries {
+		if entry.Kind == kind {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestStepTimeoutIsLoggedOnceAcrossConflicts(t *testing.T) {
+	ctx := context.Background()
+	c, repo := newConflictingCoordinator()
+
+	c.Workflow("shipping", 1).
+		StartOn("order.paid").
+		RegisterStep(saga.StepDefinition{Command: "cmd.ship", Queue: "shipping", SuccessEvent: "shipped", Timeout: time.Minute}).
+		On("order.paid", func(_ context.Context, e *saga.Event) error {
+			return e.SendCommand("cmd.ship", nil)
+		})
+
+	msg, err := saga.NewSagaMessage("order-1", "order.paid", nil)
+	if err != nil {
+		t.Fatalf("message: %v", err)
+	}
+	if err := c.HandleEvent(ctx, msg); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	expired, err := repo.FindExpiredSteps(ctx, time.Now().Add(time.Hour), 10)
+	if err != nil || len(expired) != 1 {
+		t.Fatalf("expired steps: %v, %v", expired, err)
+	}
+
+	repo.conflicts = 2
+	if err := c.HandleTimeout(ctx, expired[0]); err != nil {
+		t.Fatalf("timeout: %v", err)
+	}
+
+	if repo.conflicts != 0 {
+		t.Fatalf("%d conflicts left, the timeout was not retried", repo.conflicts)
+	}
+	if n := historyOf(t, c, "order-1", saga.HistoryStepTimedOut); n != 1 {
+		t.Errorf("logged %d timeouts, want 1", n)
+	}
+}
+
+func TestSignalExpiryIsLoggedOnceAcrossConflicts(t *testing.T) {
+	ctx := context.Background()
+	c, repo := newConflictingCoordinator()
+
+	c.Workflow("moderation", 1).
+		StartOn("video.uploaded").
+		RegisterSignal(saga.SignalDefinition{Name: "approved", Timeout: time.Hour}).
+		On("video.uploaded", func(_ context.Context, e *saga.Event) error {
+			return e.AwaitSignal("approved")
+		}).
+		On("approved", noop)
+
+	msg, err := saga.NewSagaMessage("video-1", "video.uploaded", nil)
+	if err != nil {
+		t.Fatalf("message: %v", err)
+	}
+	if err := c.HandleEvent(ctx, msg); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	expired, err := repo.FindExpiredSignals(ctx, time.Now().Add(2*time.Hour), 10)
+	if err != nil || len(expired) != 1 {
+		t.Fatalf("expired signals: %v, %v", expired, err)
+	}
+
+	repo.conflicts = 2
+	if err := c.HandleSignalExpired(ctx, expired[0]); err != nil {
+		t.Fatalf("expiry: %v", err)
+	}
+
+	if n := historyOf(t, c, "video-1", saga.HistorySignalExpired); n != 1 {
+		t.Errorf("logged %d expiries, want 1", n)
+	}
+	if state, _ := repo.FindByCorrelationID(ctx, "video-1"); state.Status != saga.SagaStateCompensated {
+		t.Errorf("saga is %s, want COMPENSATED", state.Status)
+	}
+}
@@ -0,0 +1,37 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type InboxEntry struct {
+	MessageID     string
+	CommandType   string
+	CorrelationID string
+	ReplyEvent    string
+	ReplyPayload  json.RawMessage
+	ProcessedAt   time.Time
+}
+
+type InboxRepository interface {
+	// Claim records the message as being processed. It returns false when the
+	// message was already claimed, blocking until a concurrent claim commits.
+	Claim(ctx context.Context, messageID, commandType, correlationID string) (bool, error)
+	Find(ctx context.Context, messageID, commandType string) (*InboxEntry, error)
+	SaveReply(ctx context.Context, messageID, commandType, replyEvent string, replyPayload json.RawMessage) error
+	WithTx(tx pgx.Tx) InboxRepository
+}
+
+// inboxKey identifies a delivery for deduplication. Messages published before
+// message IDs were introduced fall back to the correlation ID.
+func inboxKey(msg *Message) string {
+	if msg.ID != "" {
+		return msg.ID
+	}
+
+	return msg.CorrelationID
+}
@@ -0,0 +1,143 @@
+package saga
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// JoinDefinition groups steps that run in parallel. The join handler runs
+// once every branch has succeeded.
+type JoinDefinition struct {
+	Name     string
+	Branches []JoinBranch
+}
+
+type JoinBranch struct {
+	Command string
+	// Compensations undo this branch. They run only if the branch completed
+	// before another branch of the join failed.
+	Compensations []string
+}
+
+// JoinState tracks the branches of a forked join in saga state.
+type JoinState struct {
+	Pending   []string `json:"pending"`
+	Completed []string `json:"completed"`
+}
+
+type join struct {
+	def     JoinDefinition
+	handler EventHandlerFunc
+}
+
+// Join registers a fan-in point. The branch commands must be registered as
+// steps with success events; Event.Fork sends them.
+func (w *Workflow) Join(def JoinDefinition, handler EventHandlerFunc) *Workflow {
+	j := &join{def: def, handler: handler}
+
+	w.joins[def.Name] = j
+	for _, branch := range def.Branches {
+		w.branchJoins[branch.Command] = j
+	}
+
+	return w
+}
+
+func (j *join) branch(cmd string) (JoinBranch, bool) {
+	for _, branch := range j.def.Branches {
+		if branch.Command == cmd {
+			return branch, true
+		}
+	}
+
+	return JoinBranch{}, false
+}
+
+// Fork sends every branch command of the join within the event's transaction.
+// payloads is keyed by branch command.
+func (e *Event) Fork(name string, payloads map[string]any) error {
+	j, ok := e.workflow.joins[name]
+	if !ok {
+		return fmt.Errorf("join not registered: %s", name)
+	}
+
+	branches := make([]string, 0, len(j.def.Branches))
+	for _, branch := range j.def.Branches {
+		payload, ok := payloads[branch.Command]
+		if !ok {
+			return fmt.Errorf("join %s: missing payload for %s", name, branch.Command)
+		}
+
+		if err := e.SendCommand(branch.Command, payload); err != nil {
+			return err
+		}
+
+		branches = append(branches, branch.Command)
+	}
+
+	if err := e.coordinator.repo.WithTx(e.tx).StartJoin(e.ctx, e.sagaState.ID, name, branches); err != nil {
+		return fmt.Errorf("start join %s: %w", name, err)
+	}
+
+	if e.sagaState.Joins == nil {
+		e.sagaState.Joins = make(map[string]*JoinState)
+	}
+	e.sagaState.Joins[name] = &JoinState{Pending: branches, Completed: []string{}}
+
+	return nil
+}
+
+// completeBranch records a branch reply and runs the join handler once it was
+// the last outstanding branch.
+func (c *Coordinator) completeBranch(ctx context.Context, tx pgx.Tx, j *join, cmd string, event *Event) error {
+	remaining, ok, err := c.repo.WithTx(tx).CompleteJoinBranch(ctx, event.sagaState.ID, j.def.Name, cmd)
+	if err != nil {
+		return fmt.Errorf("complete join branch: %w", err)
+	}
+
+	// Duplicate reply or a branch that was never forked
+	if !ok || remaining > 0 {
+		return nil
+	}
+
+	return j.handler(ctx, event)
+}
+
+// isBranch reports whether cmd is a join branch. A failed branch means the
+// join can never complete, so the saga rolls back even when nothing has to be
+// undone yet: siblings that reply later are compensated as they arrive.
+func (w *Workflow) isBranch(cmd string) bool {
+	_, ok := w.branchJoins[cmd]
+	return ok
+}
+
+// compensationsFor returns what has to be undone when cmd fails: the branches
+// of its join that already completed, followed by the step's own
+// compensations.
+func (c *Coordinator) compensationsFor(wf *Workflow, state *SagaStateEntity, cmd string) []string {
+	var comps []string
+
+	if j, ok := wf.branchJoins[cmd]; ok {
+		if js, ok := state.Joins[j.def.Name]; ok {
+			for _, completed := range js.Completed {
+				branch, _ := j.branch(completed)
+				comps = appendUnique(comps, branch.Compensations...)
+			}
+		}
+	}
+
+	return appendUnique(comps, wf.compensations[cmd]...)
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		if !slices.Contains(list, item) {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
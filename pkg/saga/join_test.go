@@ -0,0 +1,74 @@
+package saga_test
+
+import (
+	"context"
+	"testing"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+)
+
+const (
+	cmdCharge  = "cmd.charge"
+	cmdReserve = "cmd.reserve"
+)
+
+// registerCheckout forks a charge and a reservation that have nothing to
+// compensate, and completes once both succeeded.
+func registerCheckout(h *sagatest.Harness) {
+	h.Coordinator().Workflow("checkout", 1).
+		StartOn("order.placed").
+		RegisterStep(saga.StepDefinition{
+			Command:      cmdCharge,
+			Queue:        "payments",
+			SuccessEvent: "charged",
+			FailureEvent: "charge_failed",
+		}).
+		RegisterStep(saga.StepDefinition{
+			Command:      cmdReserve,
+			Queue:        "stock",
+			SuccessEvent: "reserved",
+		}).
+		On("order.placed", func(_ context.Context, e *saga.Event) error {
+			return e.Fork("checkout", map[string]any{cmdCharge: nil, cmdReserve: nil})
+		}).
+		Join(saga.JoinDefinition{
+			Name:     "checkout",
+			Branches: []saga.JoinBranch{{Command: cmdCharge}, {Command: cmdReserve}},
+		}, func(_ context.Context, e *saga.Event) error {
+			return e.Complete()
+		})
+}
+
+func TestJoinCompletesOnceEveryBranchSucceeded(t *testing.T) {
+	h := sagatest.New(t)
+	registerCheckout(h)
+
+	h.Actor("payments").Register(cmdCharge, nil, "charged", "events")
+	h.Actor("stock").Register(cmdReserve, nil, "reserved", "events")
+
+	h.Emit("order.placed", "order-1", nil)
+
+	h.AssertStatus("order-1", saga.SagaStateCompleted)
+}
+
+func TestFailedBranchWithoutCompensationFailsSaga(t *testing.T) {
+	h := sagatest.New(t)
+	registerCheckout(h)
+
+	h.Actor("payments").Register(cmdCharge, nil, "charged", "events", saga.WithFailureEvent("charge_failed"))
+	h.FailCommand(cmdCharge, saga.Fail("card_declined", "card declined"), 1)
+
+	h.Emit("order.placed", "order-1", nil)
+
+	// The reservation is still out, so the saga waits for it to roll back
+	h.AssertStatus("order-1", saga.SagaStateCompensating).
+		AssertStepStatus("order-1", cmdCharge, saga.StepStatusFailed).
+		AssertStepStatus("order-1", cmdReserve, saga.StepStatusPending)
+
+	h.Actor("stock").Register(cmdReserve, nil, "reserved", "events")
+	h.Deliver()
+
+	h.AssertStatus("order-1", saga.SagaStateCompensated).
+		AssertStepStatus("order-1", cmdReserve, saga.StepStatusCompleted)
+}
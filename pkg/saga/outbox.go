@@ -0,0 +1,36 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/oagudo/outbox"
+)
+
+// OutboxPublisher publishes the messages the coordinator and actors save to
+// the outbox. The queue is kept in the message metadata.
+type OutboxPublisher struct {
+	transport Transport
+}
+
+func NewOutboxPublisher(transport Transport) *OutboxPublisher {
+	return &OutboxPublisher{
+		transport: transport,
+	}
+}
+
+func (p *OutboxPublisher) Publish(ctx context.Context, msg *outbox.Message) error {
+	queue := string(msg.Metadata)
+	if queue == "" {
+		return fmt.Errorf("outbox publisher: queue name metadata is empty")
+	}
+
+	// The outbox stores JSON; the transport encodes it for the wire.
+	var m Message
+	if err := json.Unmarshal(msg.Payload, &m); err != nil {
+		return fmt.Errorf("outbox publisher: decode %s: %w", msg.ID, err)
+	}
+
+	return p.transport.Publish(ctx, queue, &m)
+}
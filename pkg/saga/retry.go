@@ -0,0 +1,95 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"time"
+)
+
+type ErrorKind string
+
+const (
+	ErrorKindTransient ErrorKind = "transient"
+	ErrorKindTimeout   ErrorKind = "timeout"
+	ErrorKindPermanent ErrorKind = "permanent"
+)
+
+type KindError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *KindError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *KindError) Unwrap() error {
+	return e.Err
+}
+
+func Transient(err error) error {
+	return &KindError{Kind: ErrorKindTransient, Err: err}
+}
+
+func Permanent(err error) error {
+	return &KindError{Kind: ErrorKindPermanent, Err: err}
+}
+
+// KindOf classifies a handler error. Errors that were not explicitly wrapped
+// are treated as transient, so infrastructure hiccups are retried by default.
+func KindOf(err error) ErrorKind {
+	var kindErr *KindError
+	if errors.As(err, &kindErr) {
+		return kindErr.Kind
+	}
+
+	if _, ok := AsBusinessError(err); ok {
+		return ErrorKindPermanent
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindTimeout
+	}
+
+	return ErrorKindTransient
+}
+
+type RetryPolicy struct {
+	MaxAttempts    int           `json:"max_attempts" yaml:"max_attempts"`
+	InitialBackoff time.Duration `json:"initial_backoff" yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `json:"max_backoff" yaml:"max_backoff"`
+	Multiplier     float64       `json:"multiplier,omitempty" yaml:"multiplier"`
+	RetryableKinds []ErrorKind   `json:"retryable_kinds,omitempty" yaml:"retryable_kinds"`
+}
+
+var defaultRetryableKinds = []ErrorKind{ErrorKindTransient, ErrorKindTimeout}
+
+func (p *RetryPolicy) ShouldRetry(attempt int, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+
+	kinds := p.RetryableKinds
+	if len(kinds) == 0 {
+		kinds = defaultRetryableKinds
+	}
+
+	return slices.Contains(kinds, KindOf(err))
+}
+
+// Backoff returns the delay before the given (1-based) attempt is retried.
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+
+	return time.Duration(delay)
+}
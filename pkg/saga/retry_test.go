@@ -0,0 +1,84 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}
+
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+
+	for i, w := range want {
+		if got := policy.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+
+	policy.Multiplier = 3
+	if got := policy.Backoff(3); got != 900*time.Millisecond {
+		t.Errorf("Backoff(3) with multiplier 3 = %s, want 900ms", got)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	cases := []struct {
+		err  error
+		want ErrorKind
+	}{
+		{errors.New("connection reset"), ErrorKindTransient},
+		{Permanent(errors.New("bad request")), ErrorKindPermanent},
+		{fmt.Errorf("wrapped: %w", Transient(errors.New("busy"))), ErrorKindTransient},
+		{Fail("card_declined", "card declined"), ErrorKindPermanent},
+		{fmt.Errorf("call: %w", context.DeadlineExceeded), ErrorKindTimeout},
+	}
+
+	for _, c := range cases {
+		if got := KindOf(c.err); got != c.want {
+			t.Errorf("KindOf(%v) = %s, want %s", c.err, got, c.want)
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	transient := errors.New("connection reset")
+	timeout := context.DeadlineExceeded
+	permanent := Permanent(errors.New("bad request"))
+
+	policy := &RetryPolicy{MaxAttempts: 3}
+
+	cases := []struct {
+		name    string
+		policy  *RetryPolicy
+		attempt int
+		err     error
+		want    bool
+	}{
+		{"no policy", nil, 1, transient, false},
+		{"transient", policy, 1, transient, true},
+		{"timeout", policy, 2, timeout, true},
+		{"permanent", policy, 1, permanent, false},
+		{"attempts used up", policy, 3, transient, false},
+		{"kind not listed", &RetryPolicy{MaxAttempts: 3, RetryableKinds: []ErrorKind{ErrorKindTimeout}}, 1, transient, false},
+	}
+
+	for _, c := range cases {
+		if got := c.policy.ShouldRetry(c.attempt, c.err); got != c.want {
+			t.Errorf("%s: ShouldRetry(%d) = %v, want %v", c.name, c.attempt, got, c.want)
+		}
+	}
+}
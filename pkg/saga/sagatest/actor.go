@@ -0,0 +1,84 @@
+package sagatest
+
+import (
+	"context"
+
+	"go.uber.org/fx/fxtest"
+
+	"soa-video-streaming/pkg/saga"
+)
+
+// Actor is a saga.Actor consuming its queue over the harness transport. It
+// starts on the next harness call, so every command handler should be
+// registered before that.
+type Actor struct {
+	harness       *Harness
+	queue         string
+	registrations []registration
+	actor         *saga.Actor
+}
+
+type registration struct {
+	cmdType      string
+	handler      saga.CommandHandler
+	successEvent string
+	replyQueue   string
+	opts         []saga.RegisterOption
+}
+
+// Register adds a command handler, as saga.Actor.Register does. A nil handler
+// replies with the command payload. The coordinator consumes replyQueue.
+func (a *Actor) Register(cmdType string, handler saga.CommandHandler, successEvent, replyQueue string, opts ...saga.RegisterOption) *Actor {
+	r := registration{
+		cmdType:      cmdType,
+		handler:      a.harness.inject(cmdType, handler),
+		successEvent: successEvent,
+		replyQueue:   replyQueue,
+		opts:         opts,
+	}
+
+	a.harness.consumeEvents(replyQueue)
+
+	if a.actor != nil {
+		a.actor.Register(r.cmdType, r.handler, r.successEvent, r.replyQueue, r.opts...)
+		return a
+	}
+
+	a.registrations = append(a.registrations, r)
+	return a
+}
+
+func (a *Actor) start() {
+	h := a.harness
+	h.t.Helper()
+
+	opts := []saga.ActorOption{saga.WithServiceName(a.queue)}
+	if h.schemas != nil {
+		opts = append(opts, saga.WithSchemas(h.schemas))
+	}
+
+	lc := fxtest.NewLifecycle(h.t)
+	a.actor = saga.NewActor(lc, h.bus, nil, a.queue, opts...)
+
+	for _, r := range a.registrations {
+		a.actor.Register(r.cmdType, r.handler, r.successEvent, r.replyQueue, r.opts...)
+	}
+	a.registrations = nil
+
+	lc.RequireStart()
+}
+
+// inject runs the failures set up with FailCommand before handler.
+func (h *Harness) inject(cmdType string, handler saga.CommandHandler) saga.CommandHandler {
+	return func(ctx context.Context, msg *saga.Message) (any, error) {
+		if err := h.nextFailure(cmdType); err != nil {
+			return nil, err
+		}
+
+		if handler == nil {
+			return msg.Payload, nil
+		}
+
+		return handler(ctx, msg)
+	}
+}
@@ -0,0 +1,146 @@
+package sagatest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"soa-video-streaming/pkg/saga"
+)
+
+// bus wraps a ChannelTransport and counts the messages waiting on each
+// subscribed queue, so the harness can tell when everything it started has
+// been handled. Delayed messages are held back on the harness clock instead
+// of timers.
+type bus struct {
+	transport *saga.ChannelTransport
+	now       func() time.Time
+
+	mu         sync.Mutex
+	waiting    map[string]int
+	subscribed map[string]bool
+	delayed    []delayedMessage
+}
+
+type delayedMessage struct {
+	due   time.Time
+	queue string
+	msg   *saga.Message
+	opts  []saga.PublishOption
+}
+
+func newBus(now func() time.Time) *bus {
+	return &bus{
+		// One worker, so handlers never share the Store concurrently
+		transport:  saga.NewChannelTransport(saga.WithChannelWorkers(1)),
+		now:        now,
+		waiting:    make(map[string]int),
+		subscribed: make(map[string]bool),
+	}
+}
+
+func (b *bus) Publish(ctx context.Context, queue string, msg *saga.Message, opts ...saga.PublishOption) error {
+	var o saga.PublishOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.Delay > 0 {
+		b.mu.Lock()
+		b.delayed = append(b.delayed, delayedMessage{due: b.now().Add(o.Delay), queue: queue, msg: msg, opts: opts})
+		b.mu.Unlock()
+		return nil
+	}
+
+	b.add(queue, 1)
+	if err := b.transport.Publish(ctx, queue, msg, opts...); err != nil {
+		b.add(queue, -1)
+		return err
+	}
+
+	return nil
+}
+
+func (b *bus) Subscribe(queue string, h saga.Handler, opts ...saga.SubscribeOption) error {
+	var o saga.SubscribeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	b.mu.Lock()
+	b.subscribed[queue] = true
+	b.mu.Unlock()
+
+	return b.transport.Subscribe(queue, func(ctx context.Context, msg *saga.Message) saga.Action {
+		action := h(ctx, msg)
+
+		switch {
+		case action == saga.Requeue:
+		case action == saga.Reject && o.DeadLetterQueue != "":
+			b.move(queue, o.DeadLetterQueue)
+		default:
+			b.add(queue, -1)
+		}
+
+		return action
+	}, opts...)
+}
+
+func (b *bus) Close(ctx context.Context) error {
+	return b.transport.Close(ctx)
+}
+
+// release publishes the delayed messages that are due on the harness clock.
+func (b *bus) release(ctx context.Context) error {
+	now := b.now()
+
+	b.mu.Lock()
+	var due []delayedMessage
+	later := b.delayed[:0]
+	for _, d := range b.delayed {
+		if d.due.After(now) {
+			later = append(later, d)
+		} else {
+			due = append(due, d)
+		}
+	}
+	b.delayed = later
+	b.mu.Unlock()
+
+	for _, d := range due {
+		opts := append(d.opts, saga.WithDelay(0))
+		if err := b.Publish(ctx, d.queue, d.msg, opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// idle reports whether no subscribed queue has a message left to handle.
+// Messages on queues nobody consumes do not count.
+func (b *bus) idle() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for queue, n := range b.waiting {
+		if n > 0 && b.subscribed[queue] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (b *bus) add(queue string, n int) {
+	b.mu.Lock()
+	b.waiting[queue] += n
+	b.mu.Unlock()
+}
+
+func (b *bus) move(from, to string) {
+	b.mu.Lock()
+	b.waiting[to]++
+	b.waiting[from]--
+	b.mu.Unlock()
+}
@@ -0,0 +1,462 @@
+// Package sagatest runs a saga Coordinator and its actors in memory, so
+// workflows can be tested without Postgres or RabbitMQ. Actors are real
+// saga.Actors talking to the coordinator over a saga.ChannelTransport.
+package sagatest
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"soa-video-streaming/pkg/saga"
+)
+
+// EventQueue is the coordinator's own event queue in the harness. Emitted
+// events arrive on it, and child sagas start and report back through it.
+const EventQueue = "sagatest.events"
+
+// settleTimeout bounds how long a harness call waits for the bus to go idle.
+const settleTimeout = 10 * time.Second
+
+// Harness wires a Coordinator to in-memory storage and an in-process
+// transport. Every call that publishes a message waits until the coordinator
+// and the actors have handled everything that followed from it.
+type Harness struct {
+	t           testing.TB
+	ctx         context.Context
+	Store       *Store
+	Repo        *Repository
+	TM          *TransactionManager
+	Outbox      *OutboxRepository
+	coordinator *saga.Coordinator
+	bus         *bus
+
+	actors   map[string]*Actor
+	consumed map[string]bool
+	commands []saga.Message
+	schemas  *saga.SchemaRegistry
+
+	mu       sync.Mutex
+	offset   time.Duration
+	failures map[string]*injectedFailure
+}
+
+type injectedFailure struct {
+	err error
+	// remaining is negative for failures that never run out
+	remaining int
+}
+
+func New(t testing.TB) *Harness {
+	store := NewStore()
+	repo := NewRepository(store)
+	tm := NewTransactionManager(store)
+	outboxRepo := NewOutboxRepository(store)
+
+	coordinator := saga.NewCoordinator(repo, tm, outboxRepo)
+	coordinator.UseEventQueue(EventQueue)
+
+	h := &Harness{
+		t:           t,
+		ctx:         context.Background(),
+		Store:       store,
+		Repo:        repo,
+		TM:          tm,
+		Outbox:      outboxRepo,
+		coordinator: coordinator,
+		actors:      make(map[string]*Actor),
+		consumed:    make(map[string]bool),
+		failures:    make(map[string]*injectedFailure),
+	}
+	h.bus = newBus(h.now)
+
+	h.consumeEvents(EventQueue)
+	if err := h.bus.Subscribe(saga.DeadLetterQueue, h.handleFailure); err != nil {
+		t.Fatalf("sagatest: subscribe %s: %v", saga.DeadLetterQueue, err)
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), settleTimeout)
+		defer cancel()
+
+		_ = h.bus.Close(ctx)
+	})
+
+	return h
+}
+
+// Coordinator returns the coordinator to register workflows on.
+func (h *Harness) Coordinator() *saga.Coordinator {
+	return h.coordinator
+}
+
+// UseSchemas validates events in the coordinator and commands in actors, as
+// saga.WithSchemas does.
+func (h *Harness) UseSchemas(schemas *saga.SchemaRegistry) *Harness {
+	h.schemas = schemas
+	h.coordinator.UseSchemas(schemas)
+
+	return h
+}
+
+// Actor returns the actor consuming queue. Commands sent to a queue without
+// an actor wait on it until one is registered.
+func (h *Harness) Actor(queue string) *Actor {
+	if a, ok := h.actors[queue]; ok {
+		return a
+	}
+
+	a := &Actor{harness: h, queue: queue}
+	h.actors[queue] = a
+
+	return a
+}
+
+// Emit publishes an event to the coordinator.
+func (h *Harness) Emit(eventType, correlationID string, payload any) *Harness {
+	h.t.Helper()
+
+	msg, err := saga.NewSagaMessage(correlationID, eventType, payload)
+	if err != nil {
+		h.t.Fatalf("sagatest: create event %s: %v", eventType, err)
+	}
+
+	h.startActors()
+	if err := h.bus.Publish(h.ctx, EventQueue, msg); err != nil {
+		h.t.Fatalf("sagatest: publish event %s: %v", eventType, err)
+	}
+	h.run()
+
+	return h
+}
+
+// Signal delivers an external signal, as the signal API does.
+func (h *Harness) Signal(correlationID, name string, payload any) *Harness {
+	h.t.Helper()
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		h.t.Fatalf("sagatest: marshal signal %s: %v", name, err)
+	}
+
+	if err := h.coordinator.Signal(h.ctx, correlationID, name, raw); err != nil {
+		h.t.Errorf("sagatest: signal %s: %v", name, err)
+	}
+	h.run()
+
+	return h
+}
+
+// FailCommand makes the next times deliveries of cmdType fail with err before
+// the handler runs. times <= 0 fails every delivery.
+func (h *Harness) FailCommand(cmdType string, err error, times int) *Harness {
+	if times <= 0 {
+		times = -1
+	}
+
+	h.mu.Lock()
+	h.failures[cmdType] = &injectedFailure{err: err, remaining: times}
+	h.mu.Unlock()
+
+	return h
+}
+
+// Deliver starts the actors registered since the last call. They pick up the
+// commands already waiting on their queue.
+func (h *Harness) Deliver() *Harness {
+	h.t.Helper()
+
+	h.run()
+
+	return h
+}
+
+// AdvanceTime moves the harness clock. Command retries and scheduled commands
+// that became due are delivered, then the steps and signals whose deadline
+// has passed time out.
+func (h *Harness) AdvanceTime(d time.Duration) *Harness {
+	h.t.Helper()
+
+	h.mu.Lock()
+	h.offset += d
+	h.mu.Unlock()
+
+	h.run()
+
+	steps, err := h.Repo.FindExpiredSteps(h.ctx, h.now(), 0)
+	if err != nil {
+		h.t.Fatalf("sagatest: find expired steps: %v", err)
+	}
+
+	for _, step := range steps {
+		if err := h.coordinator.HandleTimeout(h.ctx, step); err != nil {
+			h.t.Errorf("sagatest: handle timeout of %s: %v", step.StepName, err)
+		}
+	}
+
+	signals, err := h.Repo.FindExpiredSignals(h.ctx, h.now(), 0)
+	if err != nil {
+		h.t.Fatalf("sagatest: find expired signals: %v", err)
+	}
+
+	for _, signal := range signals {
+		if err := h.coordinator.HandleSignalExpired(h.ctx, signal); err != nil {
+			h.t.Errorf("sagatest: handle expiry of signal %s: %v", signal.Name, err)
+		}
+	}
+
+	h.run()
+
+	return h
+}
+
+// Commands returns every command the coordinator sent, in order.
+func (h *Harness) Commands() []saga.Message {
+	return slices.Clone(h.commands)
+}
+
+// CommandsOf returns the commands of the given type.
+func (h *Harness) CommandsOf(cmdType string) []saga.Message {
+	var cmds []saga.Message
+	for _, cmd := range h.commands {
+		if cmd.Type == cmdType {
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	return cmds
+}
+
+func (h *Harness) State(correlationID string) *saga.SagaStateEntity {
+	h.t.Helper()
+
+	state, err := h.Repo.FindByCorrelationID(h.ctx, correlationID)
+	if err != nil {
+		h.t.Fatalf("sagatest: find saga %s: %v", correlationID, err)
+	}
+
+	return state
+}
+
+func (h *Harness) AssertCommandSent(cmdType string) *Harness {
+	h.t.Helper()
+
+	if len(h.CommandsOf(cmdType)) == 0 {
+		h.t.Errorf("sagatest: expected command %s to be sent, sent %v", cmdType, h.commandTypes())
+	}
+
+	return h
+}
+
+func (h *Harness) AssertCommandNotSent(cmdType string) *Harness {
+	h.t.Helper()
+
+	if len(h.CommandsOf(cmdType)) > 0 {
+		h.t.Errorf("sagatest: expected command %s not to be sent", cmdType)
+	}
+
+	return h
+}
+
+// AssertCommands checks the exact sequence of commands sent so far.
+func (h *Harness) AssertCommands(cmdTypes ...string) *Harness {
+	h.t.Helper()
+
+	if sent := h.commandTypes(); !slices.Equal(sent, cmdTypes) {
+		h.t.Errorf("sagatest: expected commands %v, sent %v", cmdTypes, sent)
+	}
+
+	return h
+}
+
+func (h *Harness) AssertStatus(correlationID string, status saga.SagaStateStatus) *Harness {
+	h.t.Helper()
+
+	state := h.State(correlationID)
+	switch {
+	case state == nil:
+		h.t.Errorf("sagatest: saga %s does not exist", correlationID)
+	case state.Status != status:
+		h.t.Errorf("sagatest: expected saga %s to be %s, got %s", correlationID, status, state.Status)
+	}
+
+	return h
+}
+
+func (h *Harness) AssertStepStatus(correlationID, stepName string, status saga.StepStatus) *Harness {
+	h.t.Helper()
+
+	state := h.State(correlationID)
+	if state == nil {
+		h.t.Errorf("sagatest: saga %s does not exist", correlationID)
+		return h
+	}
+
+	steps, _ := h.Repo.GetSteps(h.ctx, state.ID)
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].StepName != stepName {
+			continue
+		}
+
+		if steps[i].Status != status {
+			h.t.Errorf("sagatest: expected step %s to be %s, got %s", stepName, status, steps[i].Status)
+		}
+		return h
+	}
+
+	h.t.Errorf("sagatest: saga %s has no step %s", correlationID, stepName)
+	return h
+}
+
+// GetState unmarshals the saga data into target.
+func (h *Harness) GetState(correlationID string, target any) *Harness {
+	h.t.Helper()
+
+	state := h.State(correlationID)
+	if state == nil || len(state.Data) == 0 {
+		return h
+	}
+
+	if err := json.Unmarshal(state.Data, target); err != nil {
+		h.t.Fatalf("sagatest: unmarshal saga data: %v", err)
+	}
+
+	return h
+}
+
+// now is the harness clock: wall time moved forward by AdvanceTime.
+func (h *Harness) now() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return time.Now().Add(h.offset)
+}
+
+func (h *Harness) commandTypes() []string {
+	types := make([]string, 0, len(h.commands))
+	for _, cmd := range h.commands {
+		types = append(types, cmd.Type)
+	}
+
+	return types
+}
+
+// run starts new actors, then publishes due outbox messages and retries
+// until the bus is idle and nothing more is due.
+func (h *Harness) run() {
+	h.t.Helper()
+
+	h.startActors()
+
+	for {
+		h.waitIdle()
+
+		if err := h.bus.release(h.ctx); err != nil {
+			h.t.Fatalf("sagatest: release delayed messages: %v", err)
+		}
+
+		published := h.publishOutbox()
+		if published == 0 && h.bus.idle() {
+			return
+		}
+	}
+}
+
+func (h *Harness) startActors() {
+	h.t.Helper()
+
+	for _, a := range h.actors {
+		if a.actor == nil {
+			a.start()
+		}
+	}
+}
+
+func (h *Harness) waitIdle() {
+	h.t.Helper()
+
+	deadline := time.Now().Add(settleTimeout)
+	for !h.bus.idle() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("sagatest: messages still being handled after %s", settleTimeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// publishOutbox hands the due outbox messages to the transport, as the
+// outbox reader does, and returns how many there were.
+func (h *Harness) publishOutbox() int {
+	h.t.Helper()
+
+	due := h.Store.drainOutbox(h.now())
+	for _, om := range due {
+		var msg saga.Message
+		if err := json.Unmarshal(om.Payload, &msg); err != nil {
+			h.t.Fatalf("sagatest: unmarshal outbox message: %v", err)
+		}
+
+		queue := string(om.Metadata)
+		if queue != EventQueue {
+			h.commands = append(h.commands, msg)
+		}
+
+		if err := h.bus.Publish(h.ctx, queue, &msg); err != nil {
+			h.t.Fatalf("sagatest: publish %s: %v", msg.Type, err)
+		}
+	}
+
+	return len(due)
+}
+
+// consumeEvents makes the coordinator consume queue.
+func (h *Harness) consumeEvents(queue string) {
+	h.t.Helper()
+
+	if h.consumed[queue] {
+		return
+	}
+	h.consumed[queue] = true
+
+	if err := h.bus.Subscribe(queue, h.handleEvent); err != nil {
+		h.t.Fatalf("sagatest: subscribe %s: %v", queue, err)
+	}
+}
+
+func (h *Harness) handleEvent(ctx context.Context, msg *saga.Message) saga.Action {
+	if err := h.coordinator.HandleEvent(ctx, msg); err != nil {
+		h.t.Errorf("sagatest: handle event %s: %v", msg.Type, err)
+	}
+
+	return saga.Ack
+}
+
+func (h *Harness) handleFailure(ctx context.Context, msg *saga.Message) saga.Action {
+	if err := h.coordinator.HandleFailure(ctx, msg); err != nil {
+		h.t.Errorf("sagatest: handle dead-lettered %s: %v", msg.Type, err)
+	}
+
+	return saga.Ack
+}
+
+func (h *Harness) nextFailure(cmdType string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, ok := h.failures[cmdType]
+	if !ok {
+		return nil
+	}
+
+	if f.remaining > 0 {
+		f.remaining--
+		if f.remaining == 0 {
+			delete(h.failures, cmdType)
+		}
+	}
+
+	return f.err
+}
@@ -0,0 +1,675 @@
+package sagatest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/oagudo/outbox"
+
+	"soa-video-streaming/pkg/saga"
+)
+
+type historyKey struct {
+	correlationID string
+	kind          saga.HistoryKind
+	messageID     string
+}
+
+type data struct {
+	sagas       map[string]*saga.SagaStateEntity
+	steps       []saga.SagaStep
+	history     []saga.HistoryEntry
+	historyKeys map[historyKey]bool
+	outbox      []*outbox.Message
+}
+
+func (d *data) clone() *data {
+	c := &data{
+		sagas:       make(map[string]*saga.SagaStateEntity, len(d.sagas)),
+		steps:       slices.Clone(d.steps),
+		history:     slices.Clone(d.history),
+		historyKeys: maps.Clone(d.historyKeys),
+		outbox:      slices.Clone(d.outbox),
+	}
+
+	for id, s := range d.sagas {
+		c.sagas[id] = cloneState(s)
+	}
+
+	return c
+}
+
+func cloneState(s *saga.SagaStateEntity) *saga.SagaStateEntity {
+	c := *s
+	c.Data = bytes.Clone(s.Data)
+
+	if s.Joins != nil {
+		c.Joins = make(map[string]*saga.JoinState, len(s.Joins))
+		for name, js := range s.Joins {
+			c.Joins[name] = &saga.JoinState{
+				Pending:   slices.Clone(js.Pending),
+				Completed: slices.Clone(js.Completed),
+			}
+		}
+	}
+
+	if s.Signals != nil {
+		c.Signals = make(map[string]*saga.SignalState, len(s.Signals))
+		for name, signal := range s.Signals {
+			sc := *signal
+			c.Signals[name] = &sc
+		}
+	}
+
+	return &c
+}
+
+// Store keeps saga state, steps, history and the outbox in memory. Writes made
+// inside TransactionManager.RunInTransaction are rolled back when it fails.
+// Transactions are not isolated from each other, so a Store is meant to be
+// driven from one goroutine at a time.
+type Store struct {
+	mu        sync.Mutex
+	data      *data
+	historyID int64
+}
+
+func NewStore() *Store {
+	return &Store{
+		data: &data{
+			sagas:       make(map[string]*saga.SagaStateEntity),
+			historyKeys: make(map[historyKey]bool),
+		},
+	}
+}
+
+func (s *Store) snapshot() *data {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data.clone()
+}
+
+func (s *Store) restore(d *data) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = d
+}
+
+// drainOutbox removes and returns the outbox messages scheduled up to now.
+// Later ones stay until the harness clock reaches them.
+func (s *Store) drainOutbox(now time.Time) []*outbox.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due, later []*outbox.Message
+	for _, msg := range s.data.outbox {
+		if msg.ScheduledAt.After(now) {
+			later = append(later, msg)
+		} else {
+			due = append(due, msg)
+		}
+	}
+
+	s.data.outbox = later
+	return due
+}
+
+// TransactionManager runs transactions against a Store.
+type TransactionManager struct {
+	store *Store
+}
+
+func NewTransactionManager(store *Store) *TransactionManager {
+	return &TransactionManager{store: store}
+}
+
+func (m *TransactionManager) RunInTransaction(ctx context.Context, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	tx := &memTx{store: m.store, snapshot: m.store.snapshot()}
+	if err := fn(ctx, tx); err != nil {
+		m.store.restore(tx.snapshot)
+		return err
+	}
+
+	return nil
+}
+
+// memTx only supports savepoints; any SQL call panics.
+type memTx struct {
+	pgx.Tx
+	store    *Store
+	snapshot *data
+}
+
+func (t *memTx) Begin(_ context.Context) (pgx.Tx, error) {
+	return &memTx{store: t.store, snapshot: t.store.snapshot()}, nil
+}
+
+func (t *memTx) Commit(_ context.Context) error {
+	return nil
+}
+
+func (t *memTx) Rollback(_ context.Context) error {
+	t.store.restore(t.snapshot)
+	return nil
+}
+
+// Repository is an in-memory saga.Repository. It mirrors the Postgres
+// repository, including version checks and STATE_CHANGED history entries.
+type Repository struct {
+	store *Store
+}
+
+func NewRepository(store *Store) *Repository {
+	return &Repository{store: store}
+}
+
+func (r *Repository) WithTx(_ pgx.Tx) saga.Repository {
+	return r
+}
+
+func (r *Repository) lock() *data {
+	r.store.mu.Lock()
+	return r.store.data
+}
+
+func (r *Repository) unlock() {
+	r.store.mu.Unlock()
+}
+
+func (r *Repository) Create(_ context.Context, correlationID, workflow string, version int, status saga.SagaStateStatus, payload json.RawMessage) (*saga.SagaStateEntity, error) {
+	d := r.lock()
+	defer r.unlock()
+
+	if _, ok := d.sagas[correlationID]; ok {
+		return nil, saga.ErrConcurrentUpdate
+	}
+
+	now := time.Now()
+	state := &saga.SagaStateEntity{
+		ID:              uuid.NewString(),
+		CorrelationID:   correlationID,
+		Workflow:        workflow,
+		WorkflowVersion: version,
+		Version:         1,
+		Status:          status,
+		Data:            bytes.Clone(payload),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	d.sagas[correlationID] = state
+	r.recordStateChange(d, state)
+
+	return cloneState(state), nil
+}
+
+func (r *Repository) Update(_ context.Context, state *saga.SagaStateEntity) error {
+	d := r.lock()
+	defer r.unlock()
+
+	stored, ok := d.sagas[state.CorrelationID]
+	if !ok || stored.Version != state.Version {
+		return saga.ErrConcurrentUpdate
+	}
+
+	changed := stored.Status != state.Status || !bytes.Equal(stored.Data, state.Data)
+
+	stored.Status = state.Status
+	stored.Data = bytes.Clone(state.Data)
+	stored.Version++
+	stored.UpdatedAt = time.Now()
+	state.Version++
+
+	if changed {
+		r.recordStateChange(d, stored)
+	}
+
+	return nil
+}
+
+func (r *Repository) Complete(_ context.Context, state *saga.SagaStateEntity) error {
+	d := r.lock()
+	defer r.unlock()
+
+	stored, ok := d.sagas[state.CorrelationID]
+	if !ok || stored.Version != state.Version {
+		return saga.ErrConcurrentUpdate
+	}
+
+	now := time.Now()
+	changed := stored.Status != saga.SagaStateCompleted
+
+	stored.Status = saga.SagaStateCompleted
+	stored.CompletedAt = &now
+	stored.UpdatedAt = now
+	stored.Version++
+
+	state.Status = saga.SagaStateCompleted
+	state.CompletedAt = &now
+	state.Version++
+
+	if changed {
+		r.recordStateChange(d, stored)
+	}
+
+	return nil
+}
+
+func (r *Repository) FindByCorrelationID(_ context.Context, correlationID string) (*saga.SagaStateEntity, error) {
+	d := r.lock()
+	defer r.unlock()
+
+	stored, ok := d.sagas[correlationID]
+	if !ok {
+		return nil, nil
+	}
+
+	return cloneState(stored), nil
+}
+
+func (r *Repository) List(_ context.Context, filter saga.SagaFilter) ([]saga.SagaStateEntity, error) {
+	d := r.lock()
+	defer r.unlock()
+
+	var sagas []saga.SagaStateEntity
+	for _, s := range d.sagas {
+		switch {
+		case filter.Workflow != "" && s.Workflow != filter.Workflow:
+		case filter.Status != "" && s.Status != filter.Status:
+		case filter.CreatedAfter != nil && s.CreatedAt.Before(*filter.CreatedAfter):
+		case filter.CreatedBefore != nil && s.CreatedAt.After(*filter.CreatedBefore):
+		default:
+			sagas = append(sagas, *cloneState(s))
+		}
+	}
+
+	sort.Slice(sagas, func(i, j int) bool {
+		return sagas[i].CreatedAt.After(sagas[j].CreatedAt)
+	})
+
+	if filter.Offset >= len(sagas) {
+		return nil, nil
+	}
+	sagas = sagas[filter.Offset:]
+
+	if filter.Limit > 0 && filter.Limit < len(sagas) {
+		sagas = sagas[:filter.Limit]
+	}
+
+	return sagas, nil
+}
+
+func (r *Repository) SetParent(_ context.Context, sagaStateID, parentCorrelationID string) error {
+	d := r.lock()
+	defer r.unlock()
+
+	if state := r.byID(d, sagaStateID); state != nil {
+		state.ParentCorrelationID = parentCorrelationID
+	}
+
+	return nil
+}
+
+func (r *Repository) ListChildren(_ context.Context, parentCorrelationID string) ([]saga.SagaStateEntity, error) {
+	d := r.lock()
+	defer r.unlock()
+
+	var children []saga.SagaStateEntity
+	for _, s := range d.sagas {
+		if s.ParentCorrelationID == parentCorrelationID {
+			children = append(children, *cloneState(s))
+		}
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].CreatedAt.Before(children[j].CreatedAt)
+	})
+
+	return children, nil
+}
+
+func (r *Repository) AddStep(_ context.Context, step saga.SagaStep) error {
+	d := r.lock()
+	defer r.unlock()
+
+	step.ID = uuid.NewString()
+	step.Payload = bytes.Clone(step.Payload)
+	step.CreatedAt = time.Now()
+	d.steps = append(d.steps, step)
+
+	return nil
+}
+
+func (r *Repository) UpdateStep(_ context.Context, sagaStateID, stepName string, status saga.StepStatus, attempts int, errorMessage string) error {
+	d := r.lock()
+	defer r.unlock()
+
+	now := time.Now()
+	for i := range d.steps {
+		step := &d.steps[i]
+		if step.SagaStateID != sagaStateID || step.StepName != stepName {
+			continue
+		}
+
+		step.Status = status
+		step.ErrorMessage = errorMessage
+		step.ExecutedAt = &now
+		step.Attempts = max(step.Attempts, attempts)
+	}
+
+	return nil
+}
+
+func (r *Repository) ResetStep(_ context.Context, sagaStateID, stepName string, deadlineAt *time.Time) error {
+	d := r.lock()
+	defer r.unlock()
+
+	for i := range d.steps {
+		step := &d.steps[i]
+		if step.SagaStateID != sagaStateID || step.StepName != stepName {
+			continue
+		}
+
+		step.Status = saga.StepStatusPending
+		step.ErrorMessage = ""
+		step.ExecutedAt = nil
+		step.DeadlineAt = deadlineAt
+	}
+
+	return nil
+}
+
+func (r *Repository) MarkCompensated(_ context.Context, sagaStateID, stepName string, attempts int) error {
+	d := r.lock()
+	defer r.unlock()
+
+	now := time.Now()
+	for i := range d.steps {
+		step := &d.steps[i]
+		if step.SagaStateID != sagaStateID || step.StepName != stepName || !step.Compensation {
+			continue
+		}
+
+		step.Status = saga.StepStatusCompleted
+		step.ExecutedAt = &now
+		step.CompensatedAt = &now
+		step.Attempts = max(step.Attempts, attempts)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetSteps(_ context.Context, sagaStateID string) ([]saga.SagaStep, error) {
+	d := r.lock()
+	defer r.unlock()
+
+	var steps []saga.SagaStep
+	for _, step := range d.steps {
+		if step.SagaStateID == sagaStateID {
+			steps = append(steps, step)
+		}
+	}
+
+	return steps, nil
+}
+
+func (r *Repository) CancelScheduledSteps(_ context.Context, sagaStateID string) ([]saga.SagaStep, error) {
+	d := r.lock()
+	defer r.unlock()
+
+	now := time.Now()
+	var cancelled []saga.SagaStep
+	for i := range d.steps {
+		step := &d.steps[i]
+		if step.SagaStateID != sagaStateID || step.OutboxID == "" || step.Status == saga.StepStatusCancelled {
+			continue
+		}
+
+		// Already handed to the bus
+		n := len(d.outbox)
+		d.outbox = slices.DeleteFunc(d.outbox, func(msg *outbox.Message) bool {
+			return msg.ID.String() == step.OutboxID
+		})
+		if len(d.outbox) == n {
+			continue
+		}
+
+		step.Status = saga.StepStatusCancelled
+		step.ExecutedAt = &now
+		cancelled = append(cancelled, *step)
+	}
+
+	return cancelled, nil
+}
+
+func (r *Repository) FindExpiredSteps(_ context.Context, now time.Time, limit int) ([]saga.ExpiredStep, error) {
+	d := r.lock()
+	defer r.unlock()
+
+	correlationIDs := make(map[string]string, len(d.sagas))
+	running := make(map[string]bool, len(d.sagas))
+	for _, s := range d.sagas {
+		correlationIDs[s.ID] = s.CorrelationID
+		running[s.ID] = s.Status == saga.SagaStateStarted || s.Status == saga.SagaStateCompensating
+	}
+
+	var expired []saga.ExpiredStep
+	for _, step := range d.steps {
+		if step.Status != saga.StepStatusPending || step.DeadlineAt == nil || !step.DeadlineAt.Before(now) {
+			continue
+		}
+		if !running[step.SagaStateID] {
+			continue
+		}
+
+		expired = append(expired, saga.ExpiredStep{
+			SagaStep:      step,
+			CorrelationID: correlationIDs[step.SagaStateID],
+		})
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].DeadlineAt.Before(*expired[j].DeadlineAt)
+	})
+
+	if limit > 0 && limit < len(expired) {
+		expired = expired[:limit]
+	}
+
+	return expired, nil
+}
+
+func (r *Repository) StartJoin(_ context.Context, sagaStateID, join string, branches []string) error {
+	d := r.lock()
+	defer r.unlock()
+
+	state := r.byID(d, sagaStateID)
+	if state == nil {
+		return nil
+	}
+
+	if state.Joins == nil {
+		state.Joins = make(map[string]*saga.JoinState)
+	}
+	state.Joins[join] = &saga.JoinState{Pending: slices.Clone(branches), Completed: []string{}}
+
+	return nil
+}
+
+func (r *Repository) CompleteJoinBranch(_ context.Context, sagaStateID, join, branch string) (int, bool, error) {
+	d := r.lock()
+	defer r.unlock()
+
+	state := r.byID(d, sagaStateID)
+	if state == nil {
+		return 0, false, nil
+	}
+
+	js, ok := state.Joins[join]
+	if !ok || !slices.Contains(js.Pending, branch) {
+		return 0, false, nil
+	}
+
+	js.Pending = slices.DeleteFunc(js.Pending, func(b string) bool { return b == branch })
+	js.Completed = append(js.Completed, branch)
+
+	return len(js.Pending), true, nil
+}
+
+func (r *Repository) AwaitSignal(_ context.Context, sagaStateID, name string, signal saga.SignalState) error {
+	d := r.lock()
+	defer r.unlock()
+
+	state := r.byID(d, sagaStateID)
+	if state == nil {
+		return nil
+	}
+
+	if state.Signals == nil {
+		state.Signals = make(map[string]*saga.SignalState)
+	}
+	state.Signals[name] = &signal
+
+	return nil
+}
+
+func (r *Repository) TakeSignal(_ context.Context, sagaStateID, name string) (bool, error) {
+	d := r.lock()
+	defer r.unlock()
+
+	state := r.byID(d, sagaStateID)
+	if state == nil {
+		return false, nil
+	}
+
+	if _, ok := state.Signals[name]; !ok {
+		return false, nil
+	}
+	delete(state.Signals, name)
+
+	return true, nil
+}
+
+func (r *Repository) FindExpiredSignals(_ context.Context, now time.Time, limit int) ([]saga.ExpiredSignal, error) {
+	d := r.lock()
+	defer r.unlock()
+
+	var expired []saga.ExpiredSignal
+	for _, s := range d.sagas {
+		if s.Status != saga.SagaStateStarted {
+			continue
+		}
+
+		for name, signal := range s.Signals {
+			if signal.ExpiresAt == nil || !signal.ExpiresAt.Before(now) {
+				continue
+			}
+
+			expired = append(expired, saga.ExpiredSignal{
+				SagaStateID:   s.ID,
+				CorrelationID: s.CorrelationID,
+				Name:          name,
+				ExpiresAt:     *signal.ExpiresAt,
+			})
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].ExpiresAt.Before(expired[j].ExpiresAt)
+	})
+
+	if limit > 0 && limit < len(expired) {
+		expired = expired[:limit]
+	}
+
+	return expired, nil
+}
+
+func (r *Repository) AppendHistory(_ context.Context, entry saga.HistoryEntry) error {
+	d := r.lock()
+	defer r.unlock()
+
+	r.appendHistory(d, entry)
+	return nil
+}
+
+func (r *Repository) GetHistory(_ context.Context, correlationID string) ([]saga.HistoryEntry, error) {
+	d := r.lock()
+	defer r.unlock()
+
+	var entries []saga.HistoryEntry
+	for _, entry := range d.history {
+		if entry.CorrelationID == correlationID {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func (r *Repository) byID(d *data, sagaStateID string) *saga.SagaStateEntity {
+	for _, s := range d.sagas {
+		if s.ID == sagaStateID {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) appendHistory(d *data, entry saga.HistoryEntry) {
+	if entry.MessageID != "" {
+		key := historyKey{correlationID: entry.CorrelationID, kind: entry.Kind, messageID: entry.MessageID}
+		if d.historyKeys[key] {
+			return
+		}
+		d.historyKeys[key] = true
+	}
+
+	r.store.historyID++
+	entry.ID = r.store.historyID
+	entry.Payload = bytes.Clone(entry.Payload)
+	entry.CreatedAt = time.Now()
+
+	d.history = append(d.history, entry)
+}
+
+func (r *Repository) recordStateChange(d *data, state *saga.SagaStateEntity) {
+	r.appendHistory(d, saga.HistoryEntry{
+		CorrelationID: state.CorrelationID,
+		Kind:          saga.HistoryStateChanged,
+		Type:          string(state.Status),
+		Payload:       state.Data,
+	})
+}
+
+// OutboxRepository collects outbox messages in the Store.
+type OutboxRepository struct {
+	store *Store
+}
+
+func NewOutboxRepository(store *Store) *OutboxRepository {
+	return &OutboxRepository{store: store}
+}
+
+func (r *OutboxRepository) Save(_ context.Context, msg *outbox.Message) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.data.outbox = append(r.store.data.outbox, msg)
+	return nil
+}
+
+func (r *OutboxRepository) WithTx(_ pgx.Tx) saga.OutboxRepository {
+	return r
+}
@@ -0,0 +1,64 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// ScheduleCommand sends a command once at has passed. The outbox holds it
+// until then, and it is cancelled if the saga completes, compensates or is
+// aborted first. The step timeout counts from at.
+func (e *Event) ScheduleCommand(cmdType string, payload any, at time.Time) error {
+	if !at.After(time.Now()) {
+		return e.SendCommand(cmdType, payload)
+	}
+
+	return e.sendCommand(cmdType, payload, &at)
+}
+
+// SendCommandAfter schedules a command to be sent delay from now.
+func (e *Event) SendCommandAfter(cmdType string, payload any, delay time.Duration) error {
+	return e.ScheduleCommand(cmdType, payload, time.Now().Add(delay))
+}
+
+// cancelScheduled drops the scheduled commands of a saga that are still
+// waiting in the outbox. A command the outbox reader has already picked up
+// is delivered anyway; its reply finds the saga finished and is ignored.
+func (c *Coordinator) cancelScheduled(ctx context.Context, tx pgx.Tx, state *SagaStateEntity) error {
+	repo := c.repo.WithTx(tx)
+
+	steps, err := repo.CancelScheduledSteps(ctx, state.ID)
+	if err != nil {
+		return fmt.Errorf("cancel scheduled commands: %w", err)
+	}
+
+	for _, step := range steps {
+		logrus.WithFields(logrus.Fields{
+			"correlation_id": state.CorrelationID,
+			"cmd":            step.StepName,
+			"scheduled_at":   step.ScheduledAt,
+		}).Info("Cancelled scheduled saga command")
+
+		payload, err := json.Marshal(map[string]any{"scheduled_at": step.ScheduledAt})
+		if err != nil {
+			return fmt.Errorf("marshal cancelled command %s: %w", step.StepName, err)
+		}
+
+		err = repo.AppendHistory(ctx, HistoryEntry{
+			CorrelationID: state.CorrelationID,
+			Kind:          HistoryCommandCancelled,
+			Type:          step.StepName,
+			Payload:       payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
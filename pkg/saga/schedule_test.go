@@ -0,0 +1,105 @@
+package saga_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+)
+
+// registerTrial activates a subscription right away and reminds the user a
+// day later, unless the saga has ended by then.
+func registerTrial(h *sagatest.Harness) {
+	h.Coordinator().Workflow("trial", 1).
+		StartOn("trial.started").
+		RegisterStep(saga.StepDefinition{
+			Command:       "cmd.activate",
+			Queue:         "billing",
+			SuccessEvent:  "activated",
+			FailureEvent:  "activation_failed",
+			Compensations: []string{"cmd.close_account"},
+		}).
+		RegisterStep(saga.StepDefinition{
+			Command:      "cmd.remind",
+			Queue:        "notifications",
+			SuccessEvent: "reminded",
+		}).
+		RegisterCompensationQueue("cmd.close_account", "accounts").
+		On("trial.started", func(_ context.Context, e *saga.Event) error {
+			if err := e.SendCommandAfter("cmd.remind", nil, 24*time.Hour); err != nil {
+				return err
+			}
+
+			return e.SendCommand("cmd.activate", nil)
+		}).
+		On("activated", func(_ context.Context, _ *saga.Event) error {
+			return nil
+		}).
+		On("reminded", func(_ context.Context, e *saga.Event) error {
+			return e.Complete()
+		})
+
+	h.Actor("notifications").Register("cmd.remind", nil, "reminded", "events")
+	h.Actor("accounts").Register("cmd.close_account", nil, "", "events")
+}
+
+func TestScheduledCommandIsSentWhenDue(t *testing.T) {
+	h := sagatest.New(t)
+	registerTrial(h)
+	h.Actor("billing").Register("cmd.activate", nil, "activated", "events")
+
+	h.Emit("trial.started", "trial-1", nil)
+
+	h.AssertCommands("cmd.activate").
+		AssertStepStatus("trial-1", "cmd.remind", saga.StepStatusPending)
+
+	h.AdvanceTime(23 * time.Hour)
+	h.AssertCommandNotSent("cmd.remind")
+
+	h.AdvanceTime(2 * time.Hour)
+	h.AssertCommands("cmd.activate", "cmd.remind").
+		AssertStatus("trial-1", saga.SagaStateCompleted).
+		AssertStepStatus("trial-1", "cmd.remind", saga.StepStatusCompleted)
+}
+
+func TestScheduledCommandIsCancelledWhenSagaCompensates(t *testing.T) {
+	h := sagatest.New(t)
+	registerTrial(h)
+	h.Actor("billing").Register("cmd.activate", nil, "activated", "events",
+		saga.WithFailureEvent("activation_failed"))
+	h.FailCommand("cmd.activate", saga.Fail("card_declined", "card declined"), 1)
+
+	h.Emit("trial.started", "trial-1", nil)
+
+	h.AssertStatus("trial-1", saga.SagaStateCompensated).
+		AssertStepStatus("trial-1", "cmd.remind", saga.StepStatusCancelled)
+
+	h.AdvanceTime(25 * time.Hour)
+	h.AssertCommands("cmd.activate", "cmd.close_account")
+}
+
+func TestScheduledCommandIsCancelledWhenSagaCompletes(t *testing.T) {
+	h := sagatest.New(t)
+
+	h.Coordinator().Workflow("trial", 1).
+		StartOn("trial.started").
+		RegisterStep(saga.StepDefinition{Command: "cmd.remind", Queue: "notifications", SuccessEvent: "reminded"}).
+		On("trial.started", func(_ context.Context, e *saga.Event) error {
+			return e.SendCommandAfter("cmd.remind", nil, 24*time.Hour)
+		}).
+		On("trial.converted", func(_ context.Context, e *saga.Event) error {
+			return e.Complete()
+		})
+	h.Actor("notifications").Register("cmd.remind", nil, "reminded", "events")
+
+	h.Emit("trial.started", "trial-1", nil).
+		Emit("trial.converted", "trial-1", nil)
+
+	h.AssertStatus("trial-1", saga.SagaStateCompleted).
+		AssertStepStatus("trial-1", "cmd.remind", saga.StepStatusCancelled)
+
+	h.AdvanceTime(25 * time.Hour)
+	h.AssertCommandNotSent("cmd.remind")
+}
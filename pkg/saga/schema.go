@@ -0,0 +1,139 @@
+package saga
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var ErrInvalidPayload = errors.New("invalid payload")
+
+// InvalidPayloadCode is the business error code of commands rejected by
+// schema validation.
+const InvalidPayloadCode = "invalid_payload"
+
+// SchemaRegistry ties message types to payload structs whose `validate` tags
+// describe the schema. Types without a schema are not validated.
+type SchemaRegistry struct {
+	schemas  map[string]reflect.Type
+	validate *validator.Validate
+}
+
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		schemas:  make(map[string]reflect.Type),
+		validate: newValidator(),
+	}
+}
+
+// payloadValidator checks payloads decoded by the typed handlers.
+var payloadValidator = newValidator()
+
+func newValidator() *validator.Validate {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON name, as they appear on the wire
+	validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	return validate
+}
+
+// Register sets the schema of msgType. schema is a struct value, e.g.
+// BucketPayload{}.
+func (r *SchemaRegistry) Register(msgType string, schema any) *SchemaRegistry {
+	t := reflect.TypeOf(schema)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("saga: schema of %s must be a struct, got %s", msgType, t))
+	}
+
+	r.schemas[msgType] = t
+	return r
+}
+
+// Validate checks the payload of msg against its schema. The error wraps
+// ErrInvalidPayload and lists every violated field.
+func (r *SchemaRegistry) Validate(msg *Message) error {
+	if r == nil {
+		return nil
+	}
+
+	t, ok := r.schemas[msg.Type]
+	if !ok {
+		return nil
+	}
+
+	return decodePayload(r.validate, msg, reflect.New(t).Interface())
+}
+
+// decodePayload unmarshals the payload of msg into target, a pointer, and
+// validates it if it is a struct.
+func decodePayload(validate *validator.Validate, msg *Message, target any) error {
+	if err := json.Unmarshal(msg.Payload, target); err != nil {
+		return fmt.Errorf("%w for %s: %v", ErrInvalidPayload, msg.Type, err)
+	}
+
+	v := reflect.ValueOf(target)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return fmt.Errorf("%w for %s: payload is empty", ErrInvalidPayload, msg.Type)
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	err := validate.Struct(v.Interface())
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return fmt.Errorf("%w for %s: %v", ErrInvalidPayload, msg.Type, err)
+	}
+
+	reasons := make([]string, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		reasons = append(reasons, describe(fe))
+	}
+
+	return fmt.Errorf("%w for %s: %s", ErrInvalidPayload, msg.Type, strings.Join(reasons, "; "))
+}
+
+func describe(fe validator.FieldError) string {
+	field := strings.SplitN(fe.Namespace(), ".", 2)
+	name := field[len(field)-1]
+
+	switch fe.Tag() {
+	case "required":
+		return name + " is required"
+	case "email":
+		return name + " must be a valid email"
+	case "uuid", "uuid4":
+		return name + " must be a UUID"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", name, fe.Param())
+	case "min", "max", "len":
+		return fmt.Sprintf("%s must have %s %s", name, fe.Tag(), fe.Param())
+	default:
+		return fmt.Sprintf("%s failed %s validation", name, fe.Tag())
+	}
+}
@@ -0,0 +1,154 @@
+package saga_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/fx/fxtest"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+)
+
+type bucketPayload struct {
+	UserID     string `json:"user_id" validate:"required,uuid"`
+	BucketName string `json:"bucket_name" validate:"required,min=3"`
+	Tier       string `json:"tier" validate:"omitempty,oneof=free pro"`
+}
+
+func schemaTestMessage(t *testing.T, msgType string, payload any) *saga.Message {
+	t.Helper()
+
+	msg, err := saga.NewSagaMessage("saga-1", msgType, payload)
+	if err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+
+	return msg
+}
+
+func TestSchemaRegistryValidate(t *testing.T) {
+	schemas := saga.NewSchemaRegistry().Register("cmd.create_bucket", bucketPayload{})
+
+	cases := []struct {
+		name    string
+		msgType string
+		payload any
+		reasons []string
+	}{
+		{
+			name:    "valid",
+			msgType: "cmd.create_bucket",
+			payload: bucketPayload{UserID: "0b8e4f4e-8f5e-4b7a-9d4c-3c2f1a0e9b6d", BucketName: "media", Tier: "pro"},
+		},
+		{
+			name:    "fields named as on the wire",
+			msgType: "cmd.create_bucket",
+			payload: map[string]any{"user_id": "u-1", "tier": "gold"},
+			reasons: []string{"user_id must be a UUID", "bucket_name is required", "tier must be one of [free pro]"},
+		},
+		{
+			name:    "wrong type",
+			msgType: "cmd.create_bucket",
+			payload: map[string]any{"user_id": 42},
+			reasons: []string{"cannot unmarshal"},
+		},
+		{
+			name:    "empty",
+			msgType: "cmd.create_bucket",
+			payload: nil,
+			reasons: []string{"bucket_name is required"},
+		},
+		{
+			name:    "no schema",
+			msgType: "cmd.send_email",
+			payload: map[string]any{"anything": true},
+		},
+	}
+
+	for _, c := range cases {
+		err := schemas.Validate(schemaTestMessage(t, c.msgType, c.payload))
+
+		if len(c.reasons) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", c.name, err)
+			}
+			continue
+		}
+
+		if !errors.Is(err, saga.ErrInvalidPayload) {
+			t.Errorf("%s: got %v, want ErrInvalidPayload", c.name, err)
+			continue
+		}
+		for _, reason := range c.reasons {
+			if !strings.Contains(err.Error(), reason) {
+				t.Errorf("%s: %q does not mention %q", c.name, err, reason)
+			}
+		}
+	}
+}
+
+func TestNilSchemaRegistryAcceptsEverything(t *testing.T) {
+	var schemas *saga.SchemaRegistry
+
+	if err := schemas.Validate(schemaTestMessage(t, "cmd.create_bucket", nil)); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestActorRepliesWithFailureToInvalidCommand(t *testing.T) {
+	transport := newTransport(t)
+	replies := collect(t, transport, "events")
+
+	handled := false
+
+	lc := fxtest.NewLifecycle(t)
+	actor := saga.NewActor(lc, transport, nil, "content",
+		saga.WithSchemas(saga.NewSchemaRegistry().Register("cmd.create_bucket", bucketPayload{})))
+	actor.Register("cmd.create_bucket", func(_ context.Context, _ *saga.Message) (any, error) {
+		handled = true
+		return nil, nil
+	}, "bucket_created", "events", saga.WithFailureEvent("bucket_failed"))
+	lc.RequireStart()
+	t.Cleanup(lc.RequireStop)
+
+	publish(t, transport, "content", "saga-1", "cmd.create_bucket", map[string]string{"user_id": "u-1"})
+
+	reply := receive(t, replies)
+	if reply.Type != "bucket_failed" {
+		t.Fatalf("replied %s, want bucket_failed", reply.Type)
+	}
+
+	var failure saga.FailurePayload
+	if err := json.Unmarshal(reply.Payload, &failure); err != nil {
+		t.Fatalf("unmarshal failure: %v", err)
+	}
+	if failure.Code != saga.InvalidPayloadCode || failure.Command != "cmd.create_bucket" {
+		t.Errorf("failure %+v, want %s for cmd.create_bucket", failure, saga.InvalidPayloadCode)
+	}
+
+	if handled {
+		t.Error("handler ran on an invalid command")
+	}
+}
+
+func TestCoordinatorRejectsInvalidEvent(t *testing.T) {
+	h := sagatest.New(t).UseSchemas(saga.NewSchemaRegistry().Register("order.placed", bucketPayload{}))
+	h.Coordinator().Workflow("order", 1).
+		StartOn("order.placed").
+		On("order.placed", func(_ context.Context, e *saga.Event) error {
+			return e.Complete()
+		})
+
+	err := h.Coordinator().HandleEvent(context.Background(), schemaTestMessage(t, "order.placed", map[string]string{}))
+	if !errors.Is(err, saga.ErrInvalidPayload) {
+		t.Errorf("got %v, want ErrInvalidPayload", err)
+	}
+
+	if state := h.State("saga-1"); state != nil {
+		t.Errorf("invalid event started saga %s", state.Status)
+	}
+}
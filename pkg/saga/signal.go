@@ -0,0 +1,237 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrSignalNotAwaited = errors.New("saga is not waiting for signal")
+
+// SignalSource is the source of signals delivered through Coordinator.Signal.
+const SignalSource = "saga-signal"
+
+// SignalDefinition declares an external signal a saga can wait for, such as
+// a moderator approving an upload. The handler registered with On for Name
+// runs when the signal arrives. A signal that does not arrive within Timeout
+// compensates the saga with Compensations.
+type SignalDefinition struct {
+	Name          string
+	Timeout       time.Duration
+	Compensations []string
+	// After is the event whose handler awaits the signal. It is only used to
+	// draw the workflow.
+	After string
+}
+
+// SignalState is a signal the saga is waiting for. ExpiresAt is nil when the
+// signal has no timeout.
+type SignalState struct {
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type ExpiredSignal struct {
+	SagaStateID   string
+	CorrelationID string
+	Name          string
+	ExpiresAt     time.Time
+}
+
+func (w *Workflow) RegisterSignal(def SignalDefinition) *Workflow {
+	w.signals[def.Name] = def
+
+	if len(def.Compensations) > 0 {
+		w.compensations[def.Name] = def.Compensations
+	}
+
+	return w
+}
+
+// AwaitSignal pauses the saga until the signal is delivered with
+// Coordinator.Signal or expires.
+func (e *Event) AwaitSignal(name string) error {
+	def, ok := e.workflow.signals[name]
+	if !ok {
+		return fmt.Errorf("signal not registered: %s", name)
+	}
+
+	var signal SignalState
+	if def.Timeout > 0 {
+		expiresAt := time.Now().Add(def.Timeout)
+		signal.ExpiresAt = &expiresAt
+	}
+
+	repo := e.coordinator.repo.WithTx(e.tx)
+	if err := repo.AwaitSignal(e.ctx, e.sagaState.ID, name, signal); err != nil {
+		return fmt.Errorf("await signal %s: %w", name, err)
+	}
+
+	if e.sagaState.Signals == nil {
+		e.sagaState.Signals = make(map[string]*SignalState)
+	}
+	e.sagaState.Signals[name] = &signal
+
+	payload, err := json.Marshal(signal)
+	if err != nil {
+		return fmt.Errorf("marshal signal %s: %w", name, err)
+	}
+
+	return repo.AppendHistory(e.ctx, HistoryEntry{
+		CorrelationID: e.sagaState.CorrelationID,
+		Kind:          HistorySignalAwaited,
+		Type:          name,
+		Payload:       payload,
+	})
+}
+
+// Signal resumes a saga waiting for the named signal. The payload is handed
+// to the signal handler like the payload of an event.
+func (c *Coordinator) Signal(ctx context.Context, correlationID, name string, payload json.RawMessage) error {
+	msg, err := NewSagaMessage(correlationID, name, payload, WithSource(SignalSource))
+	if err != nil {
+		return err
+	}
+
+	ctx = ContextWithMessage(ctx, msg)
+
+	if err := c.schemas.Validate(msg); err != nil {
+		return err
+	}
+
+	return retryOnConflict(ctx, func() error {
+		return c.handleSignal(ctx, msg)
+	})
+}
+
+func (c *Coordinator) handleSignal(ctx context.Context, msg *Message) error {
+	state, err := c.repo.FindByCorrelationID(ctx, msg.CorrelationID)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return ErrSagaNotFound
+	}
+
+	if state.Status != SagaStateStarted {
+		return fmt.Errorf("%w: saga is %s", ErrInvalidSagaState, state.Status)
+	}
+
+	if _, ok := state.Signals[msg.Type]; !ok {
+		return fmt.Errorf("%w: %s", ErrSignalNotAwaited, msg.Type)
+	}
+
+	wf, err := c.workflowFor(state)
+	if err != nil {
+		return err
+	}
+
+	c.recordReceived(ctx, HistorySignalReceived, msg)
+
+	event := &Event{
+		ctx:         ctx,
+		coordinator: c,
+		workflow:    wf,
+		message:     msg,
+		sagaState:   state,
+	}
+
+	return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		repo := c.repo.WithTx(tx)
+
+		ok, err := repo.TakeSignal(ctx, state.ID, msg.Type)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrSignalNotAwaited, msg.Type)
+		}
+		delete(state.Signals, msg.Type)
+
+		event.tx = tx
+
+		if handler, ok := wf.eventHandlers[msg.Type]; ok {
+			if err := handler(ctx, event); err != nil {
+				return err
+			}
+		}
+
+		return repo.Update(ctx, state)
+	})
+}
+
+func (c *Coordinator) CompensateExpiredSignals(ctx context.Context) {
+	signals, err := c.repo.FindExpiredSignals(ctx, time.Now(), timeoutScanBatchSize)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to find expired saga signals")
+		return
+	}
+
+	for _, signal := range signals {
+		log := logrus.WithFields(logrus.Fields{
+			"correlation_id": signal.CorrelationID,
+			"signal":         signal.Name,
+		})
+
+		log.Warn("Saga signal expired, compensating")
+
+		if err := c.HandleSignalExpired(ctx, signal); err != nil {
+			log.WithError(err).Error("Failed to compensate expired saga signal")
+		}
+	}
+}
+
+func (c *Coordinator) HandleSignalExpired(ctx context.Context, signal ExpiredSignal) error {
+	return retryOnConflict(ctx, func() error {
+		return c.handleSignalExpired(ctx, signal)
+	})
+}
+
+func (c *Coordinator) handleSignalExpired(ctx context.Context, signal ExpiredSignal) error {
+	state, err := c.repo.FindByCorrelationID(ctx, signal.CorrelationID)
+	if err != nil || state == nil {
+		return fmt.Errorf("state not found for signal expiry: %w", err)
+	}
+
+	// Arrived or rolled back since the scan
+	if _, ok := state.Signals[signal.Name]; !ok || state.Status != SagaStateStarted {
+		return nil
+	}
+
+	wf, err := c.workflowFor(state)
+	if err != nil {
+		return err
+	}
+
+	c.recordReceived(ctx, HistorySignalExpired, &Message{
+		ID:            deadlineMessageID(state.ID, signal.Name, signal.ExpiresAt),
+		CorrelationID: signal.CorrelationID,
+		Type:          signal.Name,
+	})
+
+	comps := c.compensationsFor(wf, state, signal.Name)
+
+	return c.tm.RunInTransaction(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		repo := c.repo.WithTx(tx)
+
+		ok, err := repo.TakeSignal(ctx, state.ID, signal.Name)
+		if err != nil || !ok {
+			return err
+		}
+		delete(state.Signals, signal.Name)
+
+		// Without compensations the saga still ends, once nothing is pending
+		if len(comps) == 0 {
+			logrus.WithFields(logrus.Fields{
+				"correlation_id": state.CorrelationID,
+				"signal":         signal.Name,
+			}).Warn("Saga signal expired without compensation")
+		}
+
+		return c.runCompensations(ctx, tx, wf, state, comps)
+	})
+}
@@ -0,0 +1,91 @@
+package saga_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+)
+
+// registerModeration waits up to an hour for a moderator to approve an
+// upload. comps roll the upload back when the approval does not arrive.
+func registerModeration(h *sagatest.Harness, comps ...string) {
+	h.Coordinator().Workflow("moderation", 1).
+		StartOn("video.uploaded").
+		RegisterSignal(saga.SignalDefinition{
+			Name:          "approved",
+			Timeout:       time.Hour,
+			Compensations: comps,
+		}).
+		RegisterCompensationQueue("cmd.delete_video", "storage").
+		On("video.uploaded", func(_ context.Context, e *saga.Event) error {
+			return e.AwaitSignal("approved")
+		}).
+		On("approved", func(_ context.Context, e *saga.Event) error {
+			var approval map[string]string
+			if err := e.UnmarshalPayload(&approval); err != nil {
+				return err
+			}
+			if err := e.SetState(approval); err != nil {
+				return err
+			}
+
+			return e.Complete()
+		})
+}
+
+func TestSignalResumesSaga(t *testing.T) {
+	h := sagatest.New(t)
+	registerModeration(h)
+
+	h.Emit("video.uploaded", "video-1", nil)
+	h.AssertStatus("video-1", saga.SagaStateStarted)
+
+	err := h.Coordinator().Signal(context.Background(), "video-1", "rejected", nil)
+	if !errors.Is(err, saga.ErrSignalNotAwaited) {
+		t.Errorf("got %v, want ErrSignalNotAwaited", err)
+	}
+
+	h.Signal("video-1", "approved", map[string]string{"moderator": "mod-1"})
+
+	var state map[string]string
+	h.AssertStatus("video-1", saga.SagaStateCompleted).
+		GetState("video-1", &state)
+	if state["moderator"] != "mod-1" {
+		t.Errorf("signal payload not handed to the handler, state %v", state)
+	}
+}
+
+func TestExpiredSignalCompensatesSaga(t *testing.T) {
+	h := sagatest.New(t)
+	registerModeration(h, "cmd.delete_video")
+	h.Actor("storage").Register("cmd.delete_video", nil, "", "events")
+
+	h.Emit("video.uploaded", "video-1", nil)
+
+	h.AdvanceTime(30 * time.Minute)
+	h.AssertStatus("video-1", saga.SagaStateStarted).
+		AssertCommandNotSent("cmd.delete_video")
+
+	h.AdvanceTime(time.Hour)
+	h.AssertStatus("video-1", saga.SagaStateCompensated).
+		AssertCommands("cmd.delete_video")
+}
+
+func TestExpiredSignalWithoutCompensationEndsSaga(t *testing.T) {
+	h := sagatest.New(t)
+	registerModeration(h)
+
+	h.Emit("video.uploaded", "video-1", nil)
+	h.AdvanceTime(2 * time.Hour)
+
+	h.AssertStatus("video-1", saga.SagaStateCompensated)
+
+	err := h.Coordinator().Signal(context.Background(), "video-1", "approved", nil)
+	if !errors.Is(err, saga.ErrInvalidSagaState) {
+		t.Errorf("late signal: got %v, want ErrInvalidSagaState", err)
+	}
+}
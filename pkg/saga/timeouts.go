@@ -0,0 +1,77 @@
+package saga
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+const (
+	timeoutScanInterval  = 5 * time.Second
+	timeoutScanBatchSize = 50
+)
+
+func RunTimeoutScheduler(lc fx.Lifecycle, coordinator *Coordinator) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			go func() {
+				defer close(done)
+				coordinator.runTimeoutLoop(ctx, timeoutScanInterval)
+			}()
+
+			logrus.WithField("interval", timeoutScanInterval).Info("Saga timeout scheduler started")
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+}
+
+func (c *Coordinator) runTimeoutLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.CompensateExpiredSteps(ctx)
+			c.CompensateExpiredSignals(ctx)
+		}
+	}
+}
+
+func (c *Coordinator) CompensateExpiredSteps(ctx context.Context) {
+	steps, err := c.repo.FindExpiredSteps(ctx, time.Now(), timeoutScanBatchSize)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to find expired saga steps")
+		return
+	}
+
+	for _, step := range steps {
+		log := logrus.WithFields(logrus.Fields{
+			"correlation_id": step.CorrelationID,
+			"step":           step.StepName,
+		})
+
+		log.Warn("Saga step deadline exceeded, compensating")
+
+		if err := c.HandleTimeout(ctx, step); err != nil {
+			log.WithError(err).Error("Failed to compensate timed out saga step")
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package saga_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+)
+
+func TestTimedOutStepWithoutCompensationEndsSaga(t *testing.T) {
+	h := sagatest.New(t)
+
+	h.Coordinator().Workflow("shipping", 1).
+		StartOn("order.paid").
+		RegisterStep(saga.StepDefinition{
+			Command:      "cmd.ship",
+			Queue:        "shipping",
+			SuccessEvent: "shipped",
+			Timeout:      time.Minute,
+		}).
+		On("order.paid", func(_ context.Context, e *saga.Event) error {
+			return e.SendCommand("cmd.ship", nil)
+		}).
+		On("shipped", func(_ context.Context, e *saga.Event) error {
+			return e.Complete()
+		})
+
+	// Nothing consumes the shipping queue
+	h.Emit("order.paid", "order-1", nil)
+
+	h.AssertStatus("order-1", saga.SagaStateStarted)
+
+	h.AdvanceTime(2 * time.Minute)
+
+	h.AssertStatus("order-1", saga.SagaStateCompensated).
+		AssertStepStatus("order-1", "cmd.ship", saga.StepStatusFailed)
+}
@@ -0,0 +1,93 @@
+package saga
+
+import (
+	"context"
+	"time"
+)
+
+// Action tells the transport how to settle a delivery once its handler
+// returns.
+type Action int
+
+const (
+	Ack Action = iota
+	// Requeue puts the message back on its queue, e.g. when shutdown cut the
+	// handler short and another instance should take over.
+	Requeue
+	// Reject drops the message, or moves it to the dead-letter queue of the
+	// subscription if it has one.
+	Reject
+)
+
+// Handler handles a message received from a queue. The context carries the
+// handler timeout and is cancelled when the transport shuts down.
+type Handler func(ctx context.Context, msg *Message) Action
+
+// Transport moves saga messages between the coordinator and its actors.
+// Subscriptions hand messages of one correlation ID to their handler one at a
+// time, in queue order, while different sagas are handled in parallel.
+type Transport interface {
+	Publish(ctx context.Context, queue string, msg *Message, opts ...PublishOption) error
+	// Subscribe starts consuming queue. It runs until the transport closes.
+	Subscribe(queue string, h Handler, opts ...SubscribeOption) error
+	// Close stops the subscriptions and waits for in-flight handlers until
+	// ctx ends, then cancels them.
+	Close(ctx context.Context) error
+}
+
+type PublishOptions struct {
+	Delay time.Duration
+	Codec Codec
+}
+
+type PublishOption func(o *PublishOptions)
+
+// WithDelay holds the message back for d.
+func WithDelay(d time.Duration) PublishOption {
+	return func(o *PublishOptions) {
+		o.Delay = d
+	}
+}
+
+// WithPublishCodec sets the wire format of the message instead of the
+// transport's default.
+func WithPublishCodec(codec Codec) PublishOption {
+	return func(o *PublishOptions) {
+		o.Codec = codec
+	}
+}
+
+type SubscribeOptions struct {
+	DeadLetterQueue string
+}
+
+type SubscribeOption func(o *SubscribeOptions)
+
+// WithDeadLetterQueue moves rejected messages to queue.
+func WithDeadLetterQueue(queue string) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.DeadLetterQueue = queue
+	}
+}
+
+func publishOptions(opts []PublishOption, codec Codec) PublishOptions {
+	o := PublishOptions{Codec: codec}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.Codec == nil {
+		o.Codec = codec
+	}
+
+	return o
+}
+
+func subscribeOptions(opts []SubscribeOption) SubscribeOptions {
+	var o SubscribeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
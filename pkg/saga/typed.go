@@ -0,0 +1,50 @@
+package saga
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Empty is the reply of commands that have nothing to report.
+type Empty struct{}
+
+// HandleCommand adapts a typed handler to CommandHandler. The payload is
+// decoded into Req and validated before fn runs; an invalid payload fails
+// with the invalid_payload business error. The message being handled is
+// available through MessageFromContext.
+func HandleCommand[Req, Resp any](fn func(ctx context.Context, req Req) (Resp, error)) CommandHandler {
+	return func(ctx context.Context, msg *Message) (any, error) {
+		var req Req
+		if err := decodePayload(payloadValidator, msg, &req); err != nil {
+			return nil, Fail(InvalidPayloadCode, err.Error())
+		}
+
+		return fn(ctx, req)
+	}
+}
+
+// HandleTxCommand is HandleCommand for handlers registered with RegisterTx.
+func HandleTxCommand[Req, Resp any](fn func(ctx context.Context, tx pgx.Tx, req Req) (Resp, error)) TxCommandHandler {
+	return func(ctx context.Context, tx pgx.Tx, msg *Message) (any, error) {
+		var req Req
+		if err := decodePayload(payloadValidator, msg, &req); err != nil {
+			return nil, Fail(InvalidPayloadCode, err.Error())
+		}
+
+		return fn(ctx, tx, req)
+	}
+}
+
+// OnEvent adapts a typed handler to EventHandlerFunc. The event payload is
+// decoded into T and validated before fn runs.
+func OnEvent[T any](fn func(ctx context.Context, event *Event, payload T) error) EventHandlerFunc {
+	return func(ctx context.Context, event *Event) error {
+		var payload T
+		if err := decodePayload(payloadValidator, event.message, &payload); err != nil {
+			return err
+		}
+
+		return fn(ctx, event, payload)
+	}
+}
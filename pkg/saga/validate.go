@@ -0,0 +1,151 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.uber.org/fx"
+)
+
+// ValidateOnStart refuses to start the app when a registered workflow is
+// misconfigured. It runs on start, once every workflow has been registered.
+func ValidateOnStart(lc fx.Lifecycle, coordinator *Coordinator) {
+	lc.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			if err := coordinator.Validate(); err != nil {
+				return fmt.Errorf("invalid saga workflows: %w", err)
+			}
+
+			return nil
+		},
+	})
+}
+
+// Validate checks the step graph of every registered workflow and reports all
+// problems at once.
+func (c *Coordinator) Validate() error {
+	var errs []error
+
+	keys := slices.SortedFunc(maps.Keys(c.workflows), func(a, b workflowKey) int {
+		if a.name != b.name {
+			return strings.Compare(a.name, b.name)
+		}
+		return a.version - b.version
+	})
+
+	for _, key := range keys {
+		errs = append(errs, c.workflows[key].validate()...)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (w *Workflow) validate() []error {
+	var errs []error
+	addErr := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", w.key(), fmt.Sprintf(format, args...)))
+	}
+
+	if len(w.startEvents) == 0 {
+		addErr("no start event declared")
+	}
+
+	for _, cmd := range slices.Sorted(maps.Keys(w.commandDest)) {
+		if w.commandDest[cmd].Queue == "" {
+			addErr("command %s has no queue", cmd)
+		}
+	}
+
+	// Every success event must move the saga forward
+	acknowledged := make(map[string]bool)
+	for _, event := range slices.Sorted(maps.Keys(w.eventToCommand)) {
+		cmd := w.eventToCommand[event]
+		acknowledged[cmd] = true
+
+		_, handled := w.eventHandlers[event]
+		_, branch := w.branchJoins[cmd]
+
+		if !handled && !branch {
+			addErr("success event %s of %s has no handler", event, cmd)
+		}
+	}
+
+	for _, step := range slices.Sorted(maps.Keys(w.compensations)) {
+		for _, comp := range w.compensations[step] {
+			w.validateCompensation(step, comp, addErr)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(w.joins)) {
+		j := w.joins[name]
+		if j.handler == nil {
+			addErr("join %s has no handler", name)
+		}
+
+		for _, branch := range j.def.Branches {
+			if _, ok := w.commandDest[branch.Command]; !ok {
+				addErr("join %s: branch %s is not a registered step", name, branch.Command)
+			}
+			if !acknowledged[branch.Command] {
+				addErr("join %s: branch %s has no success event", name, branch.Command)
+			}
+
+			for _, comp := range branch.Compensations {
+				w.validateCompensation(branch.Command, comp, addErr)
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(w.signals)) {
+		if _, ok := w.eventHandlers[name]; !ok {
+			addErr("signal %s has no handler", name)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(w.children)) {
+		def := w.children[name]
+
+		child, ok := w.coordinator.startEvents[def.StartEvent]
+		if !ok || child.name != def.Workflow {
+			addErr("child workflow %s does not start on %s", def.Workflow, def.StartEvent)
+		}
+	}
+
+	for _, event := range slices.Sorted(maps.Keys(w.eventHandlers)) {
+		if !w.produces(event) {
+			addErr("handler for %s is unreachable: no start event, step, signal or child produces it", event)
+		}
+	}
+
+	for _, event := range slices.Sorted(maps.Keys(w.failureHandlers)) {
+		if _, ok := w.failureToCommand[event]; !ok {
+			addErr("failure handler for %s is unreachable: no step declares it as failure event", event)
+		}
+	}
+
+	return errs
+}
+
+func (w *Workflow) validateCompensation(step, comp string, addErr func(format string, args ...any)) {
+	if _, ok := w.commandDest[comp]; !ok {
+		addErr("compensation %s of %s has no destination", comp, step)
+	}
+}
+
+// produces reports whether the workflow can receive event.
+func (w *Workflow) produces(event string) bool {
+	if slices.Contains(w.startEvents, event) {
+		return true
+	}
+
+	if _, ok := w.eventToCommand[event]; ok {
+		return true
+	}
+
+	_, ok := w.signals[event]
+	return ok
+}
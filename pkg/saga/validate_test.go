@@ -0,0 +1,104 @@
+package saga_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/fx/fxtest"
+
+	"soa-video-streaming/pkg/saga"
+)
+
+func noop(_ context.Context, _ *saga.Event) error {
+	return nil
+}
+
+func TestValidateAcceptsWellFormedWorkflows(t *testing.T) {
+	c := saga.NewCoordinator(nil, nil, nil)
+
+	c.Workflow("checkout", 1).
+		StartOn("order.placed").
+		RegisterStep(saga.StepDefinition{Command: cmdCharge, Queue: "payments", SuccessEvent: "charged"}).
+		RegisterStep(saga.StepDefinition{Command: cmdReserve, Queue: "stock", SuccessEvent: "reserved"}).
+		On("order.placed", noop).
+		Join(saga.JoinDefinition{
+			Name:     "checkout",
+			Branches: []saga.JoinBranch{{Command: cmdCharge}, {Command: cmdReserve}},
+		}, noop)
+
+	c.Workflow("shipping", 1).
+		StartOn("order.paid").
+		RegisterStep(saga.StepDefinition{
+			Command:       "cmd.ship",
+			Queue:         "shipping",
+			SuccessEvent:  "shipped",
+			Compensations: []string{"cmd.refund"},
+		}).
+		RegisterCompensationQueue("cmd.refund", "payments").
+		RegisterSignal(saga.SignalDefinition{Name: "delivered"}).
+		On("order.paid", noop).
+		On("shipped", noop).
+		On("delivered", noop)
+
+	if err := c.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	c := saga.NewCoordinator(nil, nil, nil)
+
+	c.Workflow("broken", 2).
+		RegisterStep(saga.StepDefinition{
+			Command:       "cmd.ship",
+			SuccessEvent:  "shipped",
+			FailureEvent:  "ship_failed",
+			Compensations: []string{"cmd.refund"},
+		}).
+		RegisterSignal(saga.SignalDefinition{Name: "delivered"}).
+		RegisterChild(saga.ChildDefinition{Workflow: "invoice", StartEvent: "invoice.requested"}).
+		On("order.cancelled", noop).
+		OnFailure("payment_failed", func(_ context.Context, _ *saga.Event, _ *saga.FailurePayload) error {
+			return nil
+		}).
+		Join(saga.JoinDefinition{
+			Name:     "pack",
+			Branches: []saga.JoinBranch{{Command: "cmd.pack"}},
+		}, noop)
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("expected the workflow to be rejected")
+	}
+
+	want := []string{
+		"broken v2: no start event declared",
+		"command cmd.ship has no queue",
+		"success event shipped of cmd.ship has no handler",
+		"compensation cmd.refund of cmd.ship has no destination",
+		"join pack: branch cmd.pack is not a registered step",
+		"join pack: branch cmd.pack has no success event",
+		"signal delivered has no handler",
+		"child workflow invoice does not start on invoice.requested",
+		"handler for order.cancelled is unreachable",
+		"failure handler for payment_failed is unreachable",
+	}
+	for _, problem := range want {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("error does not report %q:\n%v", problem, err)
+		}
+	}
+}
+
+func TestValidateOnStartRefusesBrokenWorkflows(t *testing.T) {
+	c := saga.NewCoordinator(nil, nil, nil)
+	c.Workflow("broken", 1).On("shipped", noop)
+
+	lc := fxtest.NewLifecycle(t)
+	saga.ValidateOnStart(lc, c)
+
+	if err := lc.Start(context.Background()); err == nil {
+		t.Error("expected start to fail")
+	}
+}
@@ -0,0 +1,199 @@
+package saga
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type StepDefinition struct {
+	Command      string
+	Queue        string
+	Service      string
+	SuccessEvent string
+	// FailureEvent is the event the actor replies with when it rejects the
+	// command with a business error.
+	FailureEvent  string
+	Compensations []string
+	// Timeout is how long the step may stay PENDING before the saga is
+	// compensated. Zero disables the deadline.
+	Timeout time.Duration
+	// Retry is sent along with the command and tells the actor how to retry
+	// failed attempts before the command is dead-lettered.
+	Retry *RetryPolicy
+	// After is the event whose handler sends the command. It is only used to
+	// draw the workflow.
+	After string
+}
+
+// Workflow is a named, versioned saga definition. A saga is bound to the
+// workflow version that started it, so in-flight sagas keep running on their
+// original definition after a new version is registered.
+type Workflow struct {
+	name        string
+	version     int
+	coordinator *Coordinator
+
+	startEvents          []string
+	eventHandlers        map[string]EventHandlerFunc
+	failureHandlers      map[string]FailureHandlerFunc
+	commandDest          map[string]CommandDestination
+	eventToCommand       map[string]string
+	failureToCommand     map[string]string
+	compensations        map[string][]string
+	compensationCommands map[string]CompensationDefinition
+	compensationAcks     map[string]string
+	compensationFailures map[string]string
+	joins                map[string]*join
+	branchJoins          map[string]*join
+	signals              map[string]SignalDefinition
+	children             map[string]ChildDefinition
+	// steps and completeOn only describe the workflow for diagrams
+	steps      []StepDefinition
+	completeOn []string
+}
+
+type workflowKey struct {
+	name    string
+	version int
+}
+
+func (k workflowKey) String() string {
+	return fmt.Sprintf("%s v%d", k.name, k.version)
+}
+
+func newWorkflow(c *Coordinator, name string, version int) *Workflow {
+	return &Workflow{
+		name:                 name,
+		version:              version,
+		coordinator:          c,
+		eventHandlers:        make(map[string]EventHandlerFunc),
+		failureHandlers:      make(map[string]FailureHandlerFunc),
+		commandDest:          make(map[string]CommandDestination),
+		eventToCommand:       make(map[string]string),
+		failureToCommand:     make(map[string]string),
+		compensations:        make(map[string][]string),
+		compensationCommands: make(map[string]CompensationDefinition),
+		compensationAcks:     make(map[string]string),
+		compensationFailures: make(map[string]string),
+		joins:                make(map[string]*join),
+		branchJoins:          make(map[string]*join),
+		signals:              make(map[string]SignalDefinition),
+		children:             make(map[string]ChildDefinition),
+	}
+}
+
+func (w *Workflow) Name() string {
+	return w.name
+}
+
+func (w *Workflow) Version() int {
+	return w.version
+}
+
+// StartOn declares the events that start a new saga of this workflow. Only the
+// latest registered version of a workflow starts new sagas.
+func (w *Workflow) StartOn(events ...string) *Workflow {
+	for _, event := range events {
+		w.startEvents = append(w.startEvents, event)
+		w.coordinator.registerStartEvent(event, w)
+	}
+
+	return w
+}
+
+func (w *Workflow) RegisterStep(step StepDefinition) *Workflow {
+	w.steps = append(w.steps, step)
+
+	w.commandDest[step.Command] = CommandDestination{
+		Queue:   step.Queue,
+		Service: step.Service,
+		Timeout: step.Timeout,
+		Retry:   step.Retry,
+	}
+
+	if step.SuccessEvent != "" {
+		w.eventToCommand[step.SuccessEvent] = step.Command
+	}
+
+	if step.FailureEvent != "" {
+		w.failureToCommand[step.FailureEvent] = step.Command
+	}
+
+	if len(step.Compensations) > 0 {
+		w.compensations[step.Command] = step.Compensations
+	}
+
+	return w
+}
+
+// RegisterCompensationQueue registers a fire-and-forget compensation that is
+// considered done as soon as it is dispatched.
+func (w *Workflow) RegisterCompensationQueue(cmd string, queue string) *Workflow {
+	return w.RegisterCompensation(CompensationDefinition{
+		Command: cmd,
+		Queue:   queue,
+	})
+}
+
+func (w *Workflow) On(event string, handler EventHandlerFunc) *Workflow {
+	w.eventHandlers[event] = handler
+	return w
+}
+
+// OnFailure overrides how a failure event is handled. Failure events without
+// a handler compensate the saga.
+func (w *Workflow) OnFailure(event string, handler FailureHandlerFunc) *Workflow {
+	w.failureHandlers[event] = handler
+	return w
+}
+
+func (w *Workflow) key() workflowKey {
+	return workflowKey{name: w.name, version: w.version}
+}
+
+// Workflow returns the workflow registered under name and version, creating it
+// on first use.
+func (c *Coordinator) Workflow(name string, version int) *Workflow {
+	key := workflowKey{name: name, version: version}
+	if wf, ok := c.workflows[key]; ok {
+		return wf
+	}
+
+	wf := newWorkflow(c, name, version)
+	c.workflows[key] = wf
+
+	return wf
+}
+
+func (c *Coordinator) registerStartEvent(event string, wf *Workflow) {
+	current, ok := c.startEvents[event]
+	if !ok {
+		c.startEvents[event] = wf
+		return
+	}
+
+	if current.name != wf.name {
+		logrus.WithFields(logrus.Fields{
+			"event":    event,
+			"workflow": wf.key().String(),
+			"owner":    current.key().String(),
+		}).Fatal("Start event is already declared by another workflow")
+	}
+
+	if current.version < wf.version {
+		c.startEvents[event] = wf
+	}
+}
+
+func (c *Coordinator) workflowFor(state *SagaStateEntity) (*Workflow, error) {
+	key := workflowKey{name: state.Workflow, version: state.WorkflowVersion}
+
+	wf, ok := c.workflows[key]
+	if !ok {
+		return nil, fmt.Errorf("workflow not registered: %s", key)
+	}
+
+	return wf, nil
+}
@@ -6,6 +6,7 @@ import (
 	"soa-video-streaming/pkg/httpsrv"
 	"soa-video-streaming/pkg/postgres"
 	"soa-video-streaming/pkg/rabbitmq"
+	sagatransport "soa-video-streaming/pkg/saga"
 	"soa-video-streaming/services/content-service/internal/config"
 	"soa-video-streaming/services/content-service/internal/mocks"
 	postgresRepos "soa-video-streaming/services/content-service/internal/repository/postgres"
@@ -25,6 +26,7 @@ func main() {
 		httpsrv.Module(),
 		postgres.Module(),
 		rabbitmq.Module(),
+		sagatransport.ModuleAMQPTransport(),
 		postgresRepos.Module(),
 		grpcsrv.Module(),
 		grpcsrv.ClientModule(),
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"soa-video-streaming/pkg/postgres"
+	"soa-video-streaming/pkg/saga"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type InboxRepository struct {
+	db postgres.DB
+}
+
+func NewInboxRepository(db postgres.DB) *InboxRepository {
+	return &InboxRepository{
+		db: db,
+	}
+}
+
+func (r *InboxRepository) WithTx(tx pgx.Tx) saga.InboxRepository {
+	return &InboxRepository{
+		db: tx,
+	}
+}
+
+func (r *InboxRepository) Claim(ctx context.Context, messageID, commandType, correlationID string) (bool, error) {
+	q := `INSERT INTO media_content.inbox (message_id, command_type, correlation_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (message_id, command_type) DO NOTHING`
+
+	tag, err := r.db.Exec(ctx, q, messageID, commandType, correlationID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *InboxRepository) Find(ctx context.Context, messageID, commandType string) (*saga.InboxEntry, error) {
+	q := `SELECT message_id, command_type, correlation_id, COALESCE(reply_event, ''), reply_payload, processed_at
+		FROM media_content.inbox
+		WHERE message_id = $1 AND command_type = $2`
+
+	var entry saga.InboxEntry
+	err := r.db.QueryRow(ctx, q, messageID, commandType).Scan(
+		&entry.MessageID,
+		&entry.CommandType,
+		&entry.CorrelationID,
+		&entry.ReplyEvent,
+		&entry.ReplyPayload,
+		&entry.ProcessedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (r *InboxRepository) SaveReply(ctx context.Context, messageID, commandType, replyEvent string, replyPayload json.RawMessage) error {
+	q := `UPDATE media_content.inbox
+		SET reply_event = $1, reply_payload = $2, processed_at = NOW()
+		WHERE message_id = $3 AND command_type = $4`
+
+	_, err := r.db.Exec(ctx, q, replyEvent, replyPayload, messageID, commandType)
+	return err
+}
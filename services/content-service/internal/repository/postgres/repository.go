@@ -8,6 +8,9 @@ func Module() fx.Option {
 			NewMediaContent,
 			NewCategories,
 			NewStorageRepository,
+			NewInboxRepository,
+			NewOutboxRepository,
+			NewTransactionManager,
 		),
 	)
 }
@@ -6,15 +6,20 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"soa-video-streaming/pkg/postgres"
 	"soa-video-streaming/services/content-service/internal/domain/entity"
 )
 
 type StorageRepository struct {
-	pool *pgxpool.Pool
+	db postgres.DB
 }
 
 func NewStorageRepository(pool *pgxpool.Pool) *StorageRepository {
-	return &StorageRepository{pool: pool}
+	return &StorageRepository{db: pool}
+}
+
+func (r *StorageRepository) WithTx(tx pgx.Tx) *StorageRepository {
+	return &StorageRepository{db: tx}
 }
 
 func (r *StorageRepository) Create(ctx context.Context, account entity.StorageAccount) error {
@@ -23,13 +28,13 @@ func (r *StorageRepository) Create(ctx context.Context, account entity.StorageAc
 		VALUES ($1, $2, $3, $4)
 	`
 
-	_, err := r.pool.Exec(ctx, query, account.UserID, account.BucketName, account.Status, account.CreatedAt)
+	_, err := r.db.Exec(ctx, query, account.UserID, account.BucketName, account.Status, account.CreatedAt)
 	return err
 }
 
 func (r *StorageRepository) Delete(ctx context.Context, userID string) error {
 	query := `DELETE FROM user_storage_accounts WHERE user_id = $1`
-	_, err := r.pool.Exec(ctx, query, userID)
+	_, err := r.db.Exec(ctx, query, userID)
 	return err
 }
 
@@ -41,7 +46,7 @@ func (r *StorageRepository) FindByUserID(ctx context.Context, userID string) (*e
 	`
 
 	var account entity.StorageAccount
-	err := r.pool.QueryRow(ctx, query, userID).Scan(
+	err := r.db.QueryRow(ctx, query, userID).Scan(
 		&account.UserID,
 		&account.BucketName,
 		&account.Status,
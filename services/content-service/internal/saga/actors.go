@@ -1,33 +1,52 @@
 package saga
 
 import (
+	"time"
+
 	"go.uber.org/fx"
 
-	"soa-video-streaming/pkg/rabbitmq"
 	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/services/content-service/internal/repository/postgres"
 	"soa-video-streaming/services/orchestrator-service/domain"
 )
 
-func RegisterBucketsActor(lc fx.Lifecycle, service *BucketsService, client *rabbitmq.Client) *saga.Actor {
+func RegisterBucketsActor(
+	lc fx.Lifecycle,
+	service *BucketsService,
+	transport saga.Transport,
+	tm *postgres.TransactionManager,
+	inboxRepo *postgres.InboxRepository,
+	outboxRepo *postgres.OutboxRepository,
+) *saga.Actor {
 	actor := saga.NewActor(
 		lc,
-		client.Conn,
-		nil, // No Outbox used in Content Service yet
+		transport,
+		outboxRepo,
 		domain.QueueContentCommands,
+		saga.WithTransactionManager(tm),
+		saga.WithInbox(inboxRepo),
+		saga.WithServiceName("content-service"),
+		saga.WithSchemas(domain.Schemas()),
 	)
 
-	actor.Register(
+	actor.RegisterTx(
 		domain.CmdCreateBucket,
-		service.HandleCreateBucket,
+		saga.HandleTxCommand(service.HandleCreateBucket),
 		domain.EventBucketCreated,
 		domain.QueueContentEvents,
+		saga.WithFailureEvent(domain.EventBucketFailed),
+		saga.WithRetryPolicy(saga.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Second,
+			MaxBackoff:     10 * time.Second,
+		}),
 	)
 
-	actor.Register(
+	actor.RegisterTx(
 		domain.CmdCompensateBucket,
-		service.HandleCompensateBucket,
-		"", // No success event
-		"", // No reply queue needed
+		saga.HandleTxCommand(service.HandleCompensateBucket),
+		domain.EventBucketCompensated,
+		domain.QueueContentEvents,
 	)
 
 	return actor
@@ -2,8 +2,6 @@ package saga
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"time"
 
 	"soa-video-streaming/pkg/saga"
@@ -12,6 +10,7 @@ import (
 	"soa-video-streaming/services/content-service/internal/repository/postgres"
 	"soa-video-streaming/services/orchestrator-service/domain"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/sirupsen/logrus"
 	"go.uber.org/fx"
 )
@@ -40,16 +39,11 @@ func NewBucketsService(
 	}
 }
 
-func (h *BucketsService) HandleCreateBucket(ctx context.Context, msg *saga.Message) (any, error) {
-	var payload domain.BucketPayload
-	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-		return nil, fmt.Errorf("unmarshal payload: %w", err)
-	}
-
+func (h *BucketsService) HandleCreateBucket(ctx context.Context, tx pgx.Tx, payload domain.BucketPayload) (domain.BucketPayload, error) {
 	bucketName, err := h.s3Mock.CreateBucket(payload.UserID)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to create bucket")
-		return nil, err
+		return domain.BucketPayload{}, err
 	}
 
 	account := entity.StorageAccount{
@@ -59,10 +53,10 @@ func (h *BucketsService) HandleCreateBucket(ctx context.Context, msg *saga.Messa
 		CreatedAt:  time.Now(),
 	}
 
-	if err := h.storageRepo.Create(ctx, account); err != nil {
+	if err := h.storageRepo.WithTx(tx).Create(ctx, account); err != nil {
 		logrus.WithError(err).Error("Failed to save storage account")
 		_ = h.s3Mock.DeleteBucket(bucketName)
-		return nil, err
+		return domain.BucketPayload{}, err
 	}
 
 	return domain.BucketPayload{
@@ -71,30 +65,25 @@ func (h *BucketsService) HandleCreateBucket(ctx context.Context, msg *saga.Messa
 	}, nil
 }
 
-func (h *BucketsService) HandleCompensateBucket(ctx context.Context, msg *saga.Message) (any, error) {
-	var payload domain.CompensateUserSignUpPayload
-	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-		return nil, fmt.Errorf("unmarshal payload: %w", err)
-	}
-
-	account, err := h.storageRepo.FindByUserID(ctx, payload.UserID)
+func (h *BucketsService) HandleCompensateBucket(ctx context.Context, tx pgx.Tx, payload domain.CompensateUserSignUpPayload) (saga.Empty, error) {
+	account, err := h.storageRepo.WithTx(tx).FindByUserID(ctx, payload.UserID)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to find storage account")
-		return nil, err
+		return saga.Empty{}, err
 	}
 
 	if account == nil {
 		logrus.WithField("user_id", payload.UserID).Warn("Storage account not found for compensation")
-		return nil, nil
+		return saga.Empty{}, nil
 	}
 
 	if err := h.s3Mock.DeleteBucket(account.BucketName); err != nil {
 		logrus.WithError(err).Error("Failed to delete bucket from S3")
 	}
 
-	if err := h.storageRepo.Delete(ctx, payload.UserID); err != nil {
-		return nil, err
+	if err := h.storageRepo.WithTx(tx).Delete(ctx, payload.UserID); err != nil {
+		return saga.Empty{}, err
 	}
 
-	return nil, nil
+	return saga.Empty{}, nil
 }
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"soa-video-streaming/pkg/saga"
+
 	"go.uber.org/fx"
 )
 
@@ -10,6 +12,8 @@ func Module() fx.Option {
 			NewCategoryService,
 			NewMediaContentService,
 			NewRecommendations,
+			saga.NewOutboxPublisher,
 		),
+		fx.Invoke(RunOutboxReader),
 	)
 }
@@ -0,0 +1,13 @@
+package service
+
+import (
+	"go.uber.org/fx"
+
+	"soa-video-streaming/pkg/outboxreader"
+	"soa-video-streaming/pkg/postgres"
+	"soa-video-streaming/pkg/saga"
+)
+
+func RunOutboxReader(lc fx.Lifecycle, pool *postgres.Client, publisher *saga.OutboxPublisher) {
+	outboxreader.Run(lc, pool, publisher)
+}
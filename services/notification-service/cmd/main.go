@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"soa-video-streaming/pkg/rabbitmq"
+	sagatransport "soa-video-streaming/pkg/saga"
 	"soa-video-streaming/services/notification-service/internal/config"
 	"soa-video-streaming/services/notification-service/internal/saga"
 	"soa-video-streaming/services/notification-service/internal/service"
@@ -16,6 +17,7 @@ func main() {
 	fx.New(
 		config.Module(),
 		rabbitmq.Module(),
+		sagatransport.ModuleAMQPTransport(),
 		service.Module(),
 		saga.Module(),
 	).Run()
@@ -1,7 +1,6 @@
 package saga
 
 import (
-	"soa-video-streaming/pkg/rabbitmq"
 	"soa-video-streaming/pkg/saga"
 	"soa-video-streaming/services/orchestrator-service/domain"
 
@@ -17,21 +16,24 @@ func Module() fx.Option {
 
 func RegisterNotificationActor(
 	lc fx.Lifecycle,
-	client *rabbitmq.Client,
+	transport saga.Transport,
 	handler *NotificationSagaHandler,
 ) *saga.Actor {
 	actor := saga.NewActor(
 		lc,
-		client.Conn,
+		transport,
 		nil, // No Outbox as requested
 		domain.QueueNotificationCommands,
+		saga.WithServiceName("notification-service"),
+		saga.WithSchemas(domain.Schemas()),
 	)
 
 	actor.Register(
 		domain.CmdSendEmail,
-		handler.HandleSendEmail,
+		saga.HandleCommand(handler.HandleSendEmail),
 		domain.EventEmailSent,
 		domain.QueueNotificationEvents,
+		saga.WithFailureEvent(domain.EventEmailFailed),
 	)
 
 	return actor
@@ -2,8 +2,6 @@ package saga
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 
 	"soa-video-streaming/pkg/saga"
 	"soa-video-streaming/services/orchestrator-service/domain"
@@ -19,14 +17,9 @@ func NewNotificationSagaHandler() *NotificationSagaHandler {
 	return &NotificationSagaHandler{}
 }
 
-func (h *NotificationSagaHandler) HandleSendEmail(ctx context.Context, msg *saga.Message) (any, error) {
-	var payload domain.EmailPayload
-	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-		return nil, fmt.Errorf("unmarshal payload: %w", err)
-	}
-
+func (h *NotificationSagaHandler) HandleSendEmail(ctx context.Context, payload domain.EmailPayload) (domain.EmailPayload, error) {
 	if payload.FirstName == "Artem" {
-		return nil, fmt.Errorf("first name is required")
+		return domain.EmailPayload{}, saga.Fail("invalid_recipient", "first name is required")
 	}
 
 	// Logic to send email would go here.
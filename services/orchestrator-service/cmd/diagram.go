@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/services/orchestrator-service/internal/service"
+)
+
+// runDiagram prints the diagram of a registered workflow without starting
+// the service, e.g. `orchestrator diagram -workflow register_user -format dot`.
+func runDiagram(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("diagram", flag.ContinueOnError)
+	workflow := fs.String("workflow", "", "Workflow to draw, required when several are registered")
+	version := fs.Int("version", 0, "Workflow version, latest when 0")
+	format := fs.String("format", string(saga.DiagramMermaid), "Diagram format: mermaid or dot")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	diagramFormat, err := saga.ParseDiagramFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	coordinator := saga.NewCoordinator(nil, nil, nil)
+	if err := service.RegisterWorkflows(coordinator); err != nil {
+		return fmt.Errorf("register workflows: %w", err)
+	}
+
+	name := *workflow
+	if name == "" {
+		names := make(map[string]struct{})
+		for _, wf := range coordinator.Workflows() {
+			names[wf.Name()] = struct{}{}
+			name = wf.Name()
+		}
+		if len(names) != 1 {
+			return fmt.Errorf("-workflow is required, %d workflows are registered", len(names))
+		}
+	}
+
+	diagram, err := coordinator.WorkflowDiagram(name, *version, diagramFormat)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(out, diagram)
+	return err
+}
+
+func diagramCommand(args []string) {
+	if err := runDiagram(args, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "diagram: %v\n", err)
+		os.Exit(1)
+	}
+}
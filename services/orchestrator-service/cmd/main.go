@@ -9,6 +9,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.uber.org/fx"
 
+	"soa-video-streaming/pkg/httpsrv"
 	"soa-video-streaming/pkg/postgres"
 	"soa-video-streaming/pkg/rabbitmq"
 	"soa-video-streaming/pkg/saga"
@@ -16,20 +17,29 @@ import (
 	postgresrepo "soa-video-streaming/services/orchestrator-service/internal/repository/postgres"
 	"soa-video-streaming/services/orchestrator-service/internal/service"
 	amqptransport "soa-video-streaming/services/orchestrator-service/internal/transport/amqp"
+	resttransport "soa-video-streaming/services/orchestrator-service/internal/transport/rest"
 )
 
 func main() {
 	flag.Parse()
 
+	if flag.Arg(0) == "diagram" {
+		diagramCommand(flag.Args()[1:])
+		return
+	}
+
 	app := fx.New(
 		config.Module(),
 		postgres.Module(),
 		rabbitmq.Module(),
 		saga.Module(),
-		saga.ModuleRabbitMQEventsController(),
+		saga.ModuleAMQPTransport(),
+		saga.ModuleEventsController(),
 		postgresrepo.Module(),
 		service.Module(),
 		amqptransport.Module(),
+		httpsrv.Module(),
+		resttransport.Module(),
 	)
 
 	ctx := context.Background()
@@ -5,6 +5,11 @@ const (
 	EventBucketCreated   = "event.content.bucket_created"
 	EventEmailSent       = "event.notification.email_sent"
 	EventUserCompensated = "event.user.compensated"
+
+	EventBucketCompensated = "event.content.bucket_compensated"
+
+	EventBucketFailed = "event.content.bucket_failed"
+	EventEmailFailed  = "event.notification.email_failed"
 )
 
 const (
@@ -17,6 +22,9 @@ const (
 const (
 	QueueUserSignUp = "queue.user.signup"
 	QueueSagaErrors = "queue.saga.errors"
+	// QueueSagaEvents carries events the orchestrator sends to itself, such
+	// as child saga starts and results.
+	QueueSagaEvents = "queue.saga.events"
 
 	QueueContentEvents      = "queue.content.events"
 	QueueNotificationEvents = "queue.notification.events"
@@ -28,30 +36,30 @@ const (
 )
 
 type UserSignUpPayload struct {
-	UserID    string `json:"user_id"`
-	Email     string `json:"email"`
+	UserID    string `json:"user_id" validate:"required"`
+	Email     string `json:"email" validate:"required,email"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 }
 
 type CompensateUserSignUpPayload struct {
-	UserID string `json:"user_id"`
+	UserID string `json:"user_id" validate:"required"`
 }
 
 type BucketPayload struct {
-	UserID     string `json:"user_id"`
+	UserID     string `json:"user_id" validate:"required"`
 	BucketName string `json:"bucket_name,omitempty"`
 	Error      string `json:"error,omitempty"`
 }
 
 type EmailPayload struct {
-	UserID    string `json:"user_id"`
-	Email     string `json:"email"`
+	UserID    string `json:"user_id" validate:"required"`
+	Email     string `json:"email" validate:"required,email"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	Error     string `json:"error,omitempty"`
 }
 
 type UserPayload struct {
-	UserID string `json:"user_id"`
+	UserID string `json:"user_id" validate:"required"`
 }
@@ -0,0 +1,20 @@
+package domain
+
+import (
+	sagav1 "soa-video-streaming/pkg/pb/saga"
+	"soa-video-streaming/pkg/saga"
+)
+
+// Protobuf payloads of the registration saga messages, used when services
+// publish with saga.ContentTypeProtobuf.
+func init() {
+	saga.RegisterProtoPayload(EventUserSignUp, &sagav1.UserSignUp{})
+	saga.RegisterProtoPayload(CmdCreateBucket, &sagav1.Bucket{})
+	saga.RegisterProtoPayload(EventBucketCreated, &sagav1.Bucket{})
+	saga.RegisterProtoPayload(EventBucketFailed, &sagav1.Failure{})
+	saga.RegisterProtoPayload(CmdCompensateBucket, &sagav1.CompensateUser{})
+	saga.RegisterProtoPayload(CmdSendEmail, &sagav1.Email{})
+	saga.RegisterProtoPayload(EventEmailSent, &sagav1.Email{})
+	saga.RegisterProtoPayload(EventEmailFailed, &sagav1.Failure{})
+	saga.RegisterProtoPayload(CmdCompensateUser, &sagav1.CompensateUser{})
+}
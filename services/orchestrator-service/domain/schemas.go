@@ -0,0 +1,17 @@
+package domain
+
+import "soa-video-streaming/pkg/saga"
+
+// Schemas returns the payload schemas of the registration saga messages.
+func Schemas() *saga.SchemaRegistry {
+	return saga.NewSchemaRegistry().
+		Register(EventUserSignUp, UserSignUpPayload{}).
+		Register(CmdCreateBucket, BucketPayload{}).
+		Register(EventBucketCreated, BucketPayload{}).
+		Register(EventBucketFailed, saga.FailurePayload{}).
+		Register(CmdCompensateBucket, CompensateUserSignUpPayload{}).
+		Register(CmdSendEmail, EmailPayload{}).
+		Register(EventEmailSent, EmailPayload{}).
+		Register(EventEmailFailed, saga.FailurePayload{}).
+		Register(CmdCompensateUser, CompensateUserSignUpPayload{})
+}
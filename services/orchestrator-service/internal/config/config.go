@@ -2,6 +2,7 @@ package config
 
 import (
 	"soa-video-streaming/pkg/config"
+	"soa-video-streaming/pkg/httpsrv"
 	"soa-video-streaming/pkg/postgres"
 	"soa-video-streaming/pkg/rabbitmq"
 
@@ -10,6 +11,17 @@ import (
 )
 
 type AppConfig struct {
+	HTTP struct {
+		httpsrv.Config `mapstructure:",squash"`
+	} `mapstructure:"http"`
+
+	// Admin is the internal-only listener for saga administration. It has
+	// no authentication of its own, so addr must not be reachable from
+	// outside the deployment. Leave it empty to disable the admin API.
+	Admin struct {
+		httpsrv.Config `mapstructure:",squash"`
+	} `mapstructure:"admin"`
+
 	RabbitMQ struct {
 		rabbitmq.Config `mapstructure:",squash"`
 	} `mapstructure:"rabbitmq"`
@@ -23,6 +35,10 @@ func NewAppConfig() (*AppConfig, error) {
 	return config.NewViper[AppConfig]()
 }
 
+func ProvideHTTPConfig(ac *AppConfig) *httpsrv.Config {
+	return &ac.HTTP.Config
+}
+
 func ProvideRabbitMQConfig(ac *AppConfig) *rabbitmq.Config {
 	return &ac.RabbitMQ.Config
 }
@@ -35,6 +51,7 @@ func Module() fx.Option {
 	return fx.Options(
 		fx.Provide(
 			NewAppConfig,
+			ProvideHTTPConfig,
 			ProvideRabbitMQConfig,
 			ProvidePostgresConfig,
 		),
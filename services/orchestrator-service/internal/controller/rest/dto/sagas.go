@@ -0,0 +1,188 @@
+package dto
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"soa-video-streaming/pkg/saga"
+)
+
+var once sync.Once
+var validate *validator.Validate
+
+func GetValidator() *validator.Validate {
+	once.Do(func() {
+		validate = validator.New()
+	})
+
+	return validate
+}
+
+type ListSagasRequest struct {
+	Workflow string `form:"workflow" validate:"omitempty,max=100"`
+	Status   string `form:"status" validate:"omitempty,oneof=STARTED COMPLETED COMPENSATING COMPENSATED COMPENSATION_FAILED ABORTED"`
+	MinAge   string `form:"min_age"`
+	MaxAge   string `form:"max_age"`
+	Limit    int    `form:"limit" validate:"omitempty,min=1,max=500"`
+	Offset   int    `form:"offset" validate:"omitempty,min=0"`
+}
+
+type ReasonRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type SagaResponse struct {
+	ID              string                `json:"id"`
+	CorrelationID   string                `json:"correlation_id"`
+	Workflow        string                `json:"workflow"`
+	WorkflowVersion int                   `json:"workflow_version"`
+	Version         int                   `json:"version"`
+	Status          string                `json:"status"`
+	Data            json.RawMessage       `json:"data,omitempty"`
+	Signals         map[string]*time.Time `json:"signals,omitempty"`
+	ParentID        string                `json:"parent_correlation_id,omitempty"`
+	CreatedAt       time.Time             `json:"created_at"`
+	UpdatedAt       time.Time             `json:"updated_at"`
+	CompletedAt     *time.Time            `json:"completed_at,omitempty"`
+}
+
+type SagaStepResponse struct {
+	StepName      string          `json:"step_name"`
+	ServiceName   string          `json:"service_name"`
+	Status        string          `json:"status"`
+	Compensation  bool            `json:"compensation"`
+	Attempts      int             `json:"attempts"`
+	ErrorMessage  string          `json:"error_message,omitempty"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	ExecutedAt    *time.Time      `json:"executed_at,omitempty"`
+	CompensatedAt *time.Time      `json:"compensated_at,omitempty"`
+	DeadlineAt    *time.Time      `json:"deadline_at,omitempty"`
+	ScheduledAt   *time.Time      `json:"scheduled_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type ListSagasResponse struct {
+	Sagas []SagaResponse `json:"sagas"`
+}
+
+type SagaDetailsResponse struct {
+	SagaResponse
+	Steps    []SagaStepResponse `json:"steps"`
+	Children []SagaResponse     `json:"children,omitempty"`
+}
+
+type SagaTreeResponse struct {
+	SagaResponse
+	Children []SagaTreeResponse `json:"children,omitempty"`
+}
+
+type HistoryEntryResponse struct {
+	ID        int64           `json:"id"`
+	Kind      string          `json:"kind"`
+	Type      string          `json:"type"`
+	MessageID string          `json:"message_id,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type HistoryResponse struct {
+	CorrelationID string                 `json:"correlation_id"`
+	Entries       []HistoryEntryResponse `json:"entries"`
+}
+
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+func NewSagaResponse(s saga.SagaStateEntity) SagaResponse {
+	return SagaResponse{
+		ID:              s.ID,
+		CorrelationID:   s.CorrelationID,
+		Workflow:        s.Workflow,
+		WorkflowVersion: s.WorkflowVersion,
+		Version:         s.Version,
+		Status:          string(s.Status),
+		Data:            s.Data,
+		Signals:         signalsResponse(s.Signals),
+		ParentID:        s.ParentCorrelationID,
+		CreatedAt:       s.CreatedAt,
+		UpdatedAt:       s.UpdatedAt,
+		CompletedAt:     s.CompletedAt,
+	}
+}
+
+func NewSagaDetailsResponse(d *saga.SagaDetails) SagaDetailsResponse {
+	steps := make([]SagaStepResponse, 0, len(d.Steps))
+	for _, s := range d.Steps {
+		steps = append(steps, SagaStepResponse{
+			StepName:      s.StepName,
+			ServiceName:   s.ServiceName,
+			Status:        string(s.Status),
+			Compensation:  s.Compensation,
+			Attempts:      s.Attempts,
+			ErrorMessage:  s.ErrorMessage,
+			Payload:       s.Payload,
+			ExecutedAt:    s.ExecutedAt,
+			CompensatedAt: s.CompensatedAt,
+			DeadlineAt:    s.DeadlineAt,
+			ScheduledAt:   s.ScheduledAt,
+			CreatedAt:     s.CreatedAt,
+		})
+	}
+
+	var children []SagaResponse
+	for _, child := range d.Children {
+		children = append(children, NewSagaResponse(child))
+	}
+
+	return SagaDetailsResponse{
+		SagaResponse: NewSagaResponse(d.State),
+		Steps:        steps,
+		Children:     children,
+	}
+}
+
+func NewSagaTreeResponse(node *saga.SagaNode) SagaTreeResponse {
+	resp := SagaTreeResponse{SagaResponse: NewSagaResponse(node.State)}
+	for _, child := range node.Children {
+		resp.Children = append(resp.Children, NewSagaTreeResponse(child))
+	}
+
+	return resp
+}
+
+func NewHistoryResponse(correlationID string, entries []saga.HistoryEntry) HistoryResponse {
+	resp := HistoryResponse{
+		CorrelationID: correlationID,
+		Entries:       make([]HistoryEntryResponse, 0, len(entries)),
+	}
+
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, HistoryEntryResponse{
+			ID:        e.ID,
+			Kind:      string(e.Kind),
+			Type:      e.Type,
+			MessageID: e.MessageID,
+			Payload:   e.Payload,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+
+	return resp
+}
+
+func signalsResponse(signals map[string]*saga.SignalState) map[string]*time.Time {
+	if len(signals) == 0 {
+		return nil
+	}
+
+	resp := make(map[string]*time.Time, len(signals))
+	for name, signal := range signals {
+		resp[name] = signal.ExpiresAt
+	}
+
+	return resp
+}
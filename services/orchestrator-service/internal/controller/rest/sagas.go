@@ -0,0 +1,266 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/services/orchestrator-service/internal/controller/rest/dto"
+)
+
+type SagasController struct {
+	coordinator *saga.Coordinator
+}
+
+func NewSagasController(c *saga.Coordinator) *SagasController {
+	return &SagasController{
+		coordinator: c,
+	}
+}
+
+func (c *SagasController) List(gc *gin.Context) {
+	var req dto.ListSagasRequest
+
+	if err := gc.ShouldBindQuery(&req); err != nil {
+		gc.JSON(http.StatusBadRequest, gin.H{"error": "invalid query"})
+		return
+	}
+
+	if err := dto.GetValidator().Struct(req); err != nil {
+		gc.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := saga.SagaFilter{
+		Workflow: req.Workflow,
+		Status:   saga.SagaStateStatus(req.Status),
+		Limit:    req.Limit,
+		Offset:   req.Offset,
+	}
+
+	now := time.Now()
+
+	if req.MinAge != "" {
+		age, err := time.ParseDuration(req.MinAge)
+		if err != nil {
+			gc.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_age"})
+			return
+		}
+		before := now.Add(-age)
+		filter.CreatedBefore = &before
+	}
+
+	if req.MaxAge != "" {
+		age, err := time.ParseDuration(req.MaxAge)
+		if err != nil {
+			gc.JSON(http.StatusBadRequest, gin.H{"error": "invalid max_age"})
+			return
+		}
+		after := now.Add(-age)
+		filter.CreatedAfter = &after
+	}
+
+	sagas, err := c.coordinator.ListSagas(gc, filter)
+	if err != nil {
+		gc.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := dto.ListSagasResponse{Sagas: make([]dto.SagaResponse, 0, len(sagas))}
+	for _, s := range sagas {
+		resp.Sagas = append(resp.Sagas, dto.NewSagaResponse(s))
+	}
+
+	gc.JSON(http.StatusOK, resp)
+}
+
+func (c *SagasController) Get(gc *gin.Context) {
+	details, err := c.coordinator.GetSaga(gc, gc.Param("correlation_id"))
+	if err != nil {
+		c.handleError(gc, err)
+		return
+	}
+
+	gc.JSON(http.StatusOK, dto.NewSagaDetailsResponse(details))
+}
+
+// Tree returns the parent/child tree the saga belongs to, from its root.
+func (c *SagasController) Tree(gc *gin.Context) {
+	tree, err := c.coordinator.SagaTree(gc, gc.Param("correlation_id"))
+	if err != nil {
+		c.handleError(gc, err)
+		return
+	}
+
+	gc.JSON(http.StatusOK, dto.NewSagaTreeResponse(tree))
+}
+
+// Diagram draws the workflow of the saga with the status of its steps.
+func (c *SagasController) Diagram(gc *gin.Context) {
+	format, ok := diagramFormat(gc)
+	if !ok {
+		return
+	}
+
+	diagram, err := c.coordinator.SagaDiagram(gc, gc.Param("correlation_id"), format)
+	if err != nil {
+		c.handleError(gc, err)
+		return
+	}
+
+	writeDiagram(gc, format, diagram)
+}
+
+// WorkflowDiagram draws a registered workflow. Without a version the latest
+// one is drawn.
+func (c *SagasController) WorkflowDiagram(gc *gin.Context) {
+	format, ok := diagramFormat(gc)
+	if !ok {
+		return
+	}
+
+	version := 0
+	if v := gc.Query("version"); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil || version < 1 {
+			gc.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+			return
+		}
+	}
+
+	diagram, err := c.coordinator.WorkflowDiagram(gc.Param("name"), version, format)
+	if err != nil {
+		c.handleError(gc, err)
+		return
+	}
+
+	writeDiagram(gc, format, diagram)
+}
+
+func (c *SagasController) History(gc *gin.Context) {
+	correlationID := gc.Param("correlation_id")
+
+	entries, err := c.coordinator.History(gc, correlationID)
+	if err != nil {
+		c.handleError(gc, err)
+		return
+	}
+
+	gc.JSON(http.StatusOK, dto.NewHistoryResponse(correlationID, entries))
+}
+
+func (c *SagasController) RetryStep(gc *gin.Context) {
+	if err := c.coordinator.RetryStep(gc, gc.Param("correlation_id"), gc.Param("step")); err != nil {
+		c.handleError(gc, err)
+		return
+	}
+
+	gc.JSON(http.StatusAccepted, dto.StatusResponse{Status: "retrying"})
+}
+
+func (c *SagasController) Compensate(gc *gin.Context) {
+	var req dto.ReasonRequest
+	if !bindReason(gc, &req) {
+		return
+	}
+
+	if err := c.coordinator.ForceCompensate(gc, gc.Param("correlation_id"), req.Reason); err != nil {
+		c.handleError(gc, err)
+		return
+	}
+
+	gc.JSON(http.StatusAccepted, dto.StatusResponse{Status: "compensating"})
+}
+
+func (c *SagasController) Abort(gc *gin.Context) {
+	var req dto.ReasonRequest
+	if !bindReason(gc, &req) {
+		return
+	}
+
+	if err := c.coordinator.Abort(gc, gc.Param("correlation_id"), req.Reason); err != nil {
+		c.handleError(gc, err)
+		return
+	}
+
+	gc.JSON(http.StatusOK, dto.StatusResponse{Status: "aborted"})
+}
+
+// Signal resumes a saga waiting for a signal. The request body, if any, is
+// the signal payload.
+func (c *SagasController) Signal(gc *gin.Context) {
+	body, err := gc.GetRawData()
+	if err != nil {
+		gc.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	var payload json.RawMessage
+	if len(body) > 0 {
+		if !json.Valid(body) {
+			gc.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+			return
+		}
+		payload = body
+	}
+
+	if err := c.coordinator.Signal(gc, gc.Param("correlation_id"), gc.Param("signal"), payload); err != nil {
+		c.handleError(gc, err)
+		return
+	}
+
+	gc.JSON(http.StatusAccepted, dto.StatusResponse{Status: "resumed"})
+}
+
+func (c *SagasController) handleError(gc *gin.Context, err error) {
+	switch {
+	case errors.Is(err, saga.ErrSagaNotFound), errors.Is(err, saga.ErrStepNotFound),
+		errors.Is(err, saga.ErrWorkflowNotFound):
+		gc.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, saga.ErrInvalidPayload):
+		gc.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, saga.ErrInvalidSagaState), errors.Is(err, saga.ErrNoCompensation),
+		errors.Is(err, saga.ErrConcurrentUpdate), errors.Is(err, saga.ErrSignalNotAwaited):
+		gc.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		gc.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func bindReason(gc *gin.Context, req *dto.ReasonRequest) bool {
+	if err := gc.ShouldBindJSON(req); err != nil {
+		gc.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return false
+	}
+
+	if err := dto.GetValidator().Struct(req); err != nil {
+		gc.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	return true
+}
+
+func diagramFormat(gc *gin.Context) (saga.DiagramFormat, bool) {
+	format, err := saga.ParseDiagramFormat(gc.Query("format"))
+	if err != nil {
+		gc.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+
+	return format, true
+}
+
+func writeDiagram(gc *gin.Context, format saga.DiagramFormat, diagram string) {
+	contentType := "text/vnd.mermaid; charset=utf-8"
+	if format == saga.DiagramDOT {
+		contentType = "text/vnd.graphviz; charset=utf-8"
+	}
+
+	gc.Data(http.StatusOK, contentType, []byte(diagram))
+}
@@ -26,7 +26,7 @@ func (r *OutboxRepository) WithTx(tx pgx.Tx) saga.OutboxRepository {
 }
 
 const insertOutboxQuery = `
-INSERT INTO outbox (id, created_at, scheduled_at, metadata, payload, times_attempted)
+INSERT INTO orchestrator_service.outbox (id, created_at, scheduled_at, metadata, payload, times_attempted)
 VALUES ($1, $2, $3, $4, $5, $6)
 `
 
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -44,14 +45,17 @@ func (r *SagaRepository) WithTx(tx pgx.Tx) saga.Repository {
 	return &SagaRepository{db: tx}
 }
 
-func (r *SagaRepository) Create(ctx context.Context, correlationID string, status saga.SagaStateStatus, data json.RawMessage) (*saga.SagaStateEntity, error) {
+func (r *SagaRepository) Create(ctx context.Context, correlationID, workflow string, version int, status saga.SagaStateStatus, data json.RawMessage) (*saga.SagaStateEntity, error) {
 	sagaState := &saga.SagaStateEntity{
-		ID:            uuid.NewString(),
-		CorrelationID: correlationID,
-		Status:        status,
-		Data:          data,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+		ID:              uuid.NewString(),
+		CorrelationID:   correlationID,
+		Workflow:        workflow,
+		WorkflowVersion: version,
+		Version:         1,
+		Status:          status,
+		Data:            data,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
 	}
 
 	var dataJSON []byte
@@ -60,14 +64,17 @@ func (r *SagaRepository) Create(ctx context.Context, correlationID string, statu
 	}
 
 	query := `
-		INSERT INTO saga_state (id, correlation_id, state, data, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO orchestrator_service.saga_state (id, correlation_id, workflow, workflow_version, version, state, data, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (correlation_id) DO NOTHING
 	`
 
-	var err error
-	_, err = r.db.Exec(ctx, query,
+	tag, err := r.db.Exec(ctx, query,
 		sagaState.ID,
 		sagaState.CorrelationID,
+		sagaState.Workflow,
+		sagaState.WorkflowVersion,
+		sagaState.Version,
 		sagaState.Status,
 		dataJSON,
 		sagaState.CreatedAt,
@@ -78,40 +85,68 @@ func (r *SagaRepository) Create(ctx context.Context, correlationID string, statu
 		return nil, err
 	}
 
+	// Another event started the same saga first
+	if tag.RowsAffected() == 0 {
+		return nil, saga.ErrConcurrentUpdate
+	}
+
 	return sagaState, nil
 }
 
-func (r *SagaRepository) Update(ctx context.Context, correlationID string, status saga.SagaStateStatus, data json.RawMessage) error {
+func (r *SagaRepository) Update(ctx context.Context, state *saga.SagaStateEntity) error {
 	var dataJSON []byte
-	if data != nil {
-		dataJSON = data
+	if state.Data != nil {
+		dataJSON = state.Data
 	}
 
 	query := `
-		UPDATE saga_state
-		SET state = $1, data = $2, updated_at = $3
-		WHERE correlation_id = $4
+		UPDATE orchestrator_service.saga_state
+		SET state = $1, data = $2, updated_at = $3, version = version + 1
+		WHERE correlation_id = $4 AND version = $5
 	`
 
-	_, err := r.db.Exec(ctx, query, status, dataJSON, time.Now(), correlationID)
-	return err
+	tag, err := r.db.Exec(ctx, query, state.Status, dataJSON, time.Now(), state.CorrelationID, state.Version)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return saga.ErrConcurrentUpdate
+	}
+
+	state.Version++
+	return nil
 }
 
-func (r *SagaRepository) Complete(ctx context.Context, correlationID string) error {
+func (r *SagaRepository) Complete(ctx context.Context, state *saga.SagaStateEntity) error {
 	query := `
-		UPDATE saga_state
-		SET state = $1, completed_at = $2, updated_at = $3
-		WHERE correlation_id = $4
+		UPDATE orchestrator_service.saga_state
+		SET state = $1, completed_at = $2, updated_at = $2, version = version + 1
+		WHERE correlation_id = $3 AND version = $4
 	`
 
-	_, err := r.db.Exec(ctx, query, saga.SagaStateCompleted, time.Now(), time.Now(), correlationID)
-	return err
+	now := time.Now()
+
+	tag, err := r.db.Exec(ctx, query, saga.SagaStateCompleted, now, state.CorrelationID, state.Version)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return saga.ErrConcurrentUpdate
+	}
+
+	state.Status = saga.SagaStateCompleted
+	state.CompletedAt = &now
+	state.Version++
+	return nil
 }
 
 func (r *SagaRepository) FindByCorrelationID(ctx context.Context, correlationID string) (*saga.SagaStateEntity, error) {
 	query := `
-		SELECT id, correlation_id, state, data, created_at, updated_at, completed_at
-		FROM saga_state
+		SELECT id, correlation_id, workflow, workflow_version, version, state, data, joins, signals,
+			COALESCE(parent_correlation_id::text, ''), created_at, updated_at, completed_at
+		FROM orchestrator_service.saga_state
 		WHERE correlation_id = $1
 	`
 
@@ -122,8 +157,14 @@ func (r *SagaRepository) FindByCorrelationID(ctx context.Context, correlationID
 	err := r.db.QueryRow(ctx, query, correlationID).Scan(
 		&sagaState.ID,
 		&sagaState.CorrelationID,
+		&sagaState.Workflow,
+		&sagaState.WorkflowVersion,
+		&sagaState.Version,
 		&sagaState.Status,
 		&dataJSON,
+		&sagaState.Joins,
+		&sagaState.Signals,
+		&sagaState.ParentCorrelationID,
 		&sagaState.CreatedAt,
 		&sagaState.UpdatedAt,
 		&completedAt,
@@ -148,40 +189,178 @@ func (r *SagaRepository) FindByCorrelationID(ctx context.Context, correlationID
 	return &sagaState, nil
 }
 
-func (r *SagaRepository) AddStep(ctx context.Context, sagaStateID, stepName, serviceName string, status saga.StepStatus) error {
+func (r *SagaRepository) List(ctx context.Context, filter saga.SagaFilter) ([]saga.SagaStateEntity, error) {
+	query := `
+		SELECT id, correlation_id, workflow, workflow_version, version, state, data, joins, signals,
+			COALESCE(parent_correlation_id::text, ''), created_at, updated_at, completed_at
+		FROM orchestrator_service.saga_state
+		WHERE 1 = 1
+	`
+
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.Workflow != "" {
+		query += " AND workflow = " + arg(filter.Workflow)
+	}
+	if filter.Status != "" {
+		query += " AND state = " + arg(filter.Status)
+	}
+	if filter.CreatedAfter != nil {
+		query += " AND created_at >= " + arg(*filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query += " AND created_at <= " + arg(*filter.CreatedBefore)
+	}
+
+	query += " ORDER BY created_at DESC LIMIT " + arg(filter.Limit) + " OFFSET " + arg(filter.Offset)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSagas(rows)
+}
+
+func (r *SagaRepository) SetParent(ctx context.Context, sagaStateID, parentCorrelationID string) error {
+	query := `
+		UPDATE orchestrator_service.saga_state
+		SET parent_correlation_id = $1
+		WHERE id = $2
+	`
+
+	_, err := r.db.Exec(ctx, query, parentCorrelationID, sagaStateID)
+	return err
+}
+
+func (r *SagaRepository) ListChildren(ctx context.Context, parentCorrelationID string) ([]saga.SagaStateEntity, error) {
+	query := `
+		SELECT id, correlation_id, workflow, workflow_version, version, state, data, joins, signals,
+			COALESCE(parent_correlation_id::text, ''), created_at, updated_at, completed_at
+		FROM orchestrator_service.saga_state
+		WHERE parent_correlation_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.Query(ctx, query, parentCorrelationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSagas(rows)
+}
+
+func scanSagas(rows pgx.Rows) ([]saga.SagaStateEntity, error) {
+	defer rows.Close()
+
+	var sagas []saga.SagaStateEntity
+	for rows.Next() {
+		var sagaState saga.SagaStateEntity
+		var dataJSON []byte
+
+		err := rows.Scan(
+			&sagaState.ID,
+			&sagaState.CorrelationID,
+			&sagaState.Workflow,
+			&sagaState.WorkflowVersion,
+			&sagaState.Version,
+			&sagaState.Status,
+			&dataJSON,
+			&sagaState.Joins,
+			&sagaState.Signals,
+			&sagaState.ParentCorrelationID,
+			&sagaState.CreatedAt,
+			&sagaState.UpdatedAt,
+			&sagaState.CompletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(dataJSON) > 0 {
+			sagaState.Data = dataJSON
+		}
+
+		sagas = append(sagas, sagaState)
+	}
+
+	return sagas, rows.Err()
+}
+
+func (r *SagaRepository) AddStep(ctx context.Context, step saga.SagaStep) error {
 	query := `
-		INSERT INTO saga_steps (id, saga_state_id, step_name, service_name, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO orchestrator_service.saga_steps (id, saga_state_id, step_name, service_name, status, payload, deadline_at, is_compensation, scheduled_at, outbox_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')::uuid, $11)
 	`
 
+	var payload []byte
+	if step.Payload != nil {
+		payload = step.Payload
+	}
+
 	_, err := r.db.Exec(ctx, query,
 		uuid.NewString(),
-		sagaStateID,
-		stepName,
-		serviceName,
-		status,
+		step.SagaStateID,
+		step.StepName,
+		step.ServiceName,
+		step.Status,
+		payload,
+		step.DeadlineAt,
+		step.Compensation,
+		step.ScheduledAt,
+		step.OutboxID,
 		time.Now(),
 	)
 
 	return err
 }
 
-func (r *SagaRepository) UpdateStep(ctx context.Context, sagaStateID, stepName string, status saga.StepStatus, errorMessage string) error {
+func (r *SagaRepository) UpdateStep(ctx context.Context, sagaStateID, stepName string, status saga.StepStatus, attempts int, errorMessage string) error {
+	now := time.Now()
+
+	query := `
+		UPDATE orchestrator_service.saga_steps
+		SET status = $1, error_message = $2, executed_at = $3, attempts = GREATEST(attempts, $4)
+		WHERE saga_state_id = $5 AND step_name = $6
+	`
+
+	_, err := r.db.Exec(ctx, query, status, errorMessage, now, attempts, sagaStateID, stepName)
+	return err
+}
+
+func (r *SagaRepository) ResetStep(ctx context.Context, sagaStateID, stepName string, deadlineAt *time.Time) error {
+	query := `
+		UPDATE orchestrator_service.saga_steps
+		SET status = $1, error_message = NULL, executed_at = NULL, deadline_at = $2
+		WHERE saga_state_id = $3 AND step_name = $4
+	`
+
+	_, err := r.db.Exec(ctx, query, saga.StepStatusPending, deadlineAt, sagaStateID, stepName)
+	return err
+}
+
+func (r *SagaRepository) MarkCompensated(ctx context.Context, sagaStateID, stepName string, attempts int) error {
 	now := time.Now()
 
 	query := `
 		UPDATE orchestrator_service.saga_steps
-		SET status = $1, error_message = $2, executed_at = $3
-		WHERE saga_state_id = $4 AND step_name = $5
+		SET status = $1, executed_at = $2, compensated_at = $2, attempts = GREATEST(attempts, $3)
+		WHERE saga_state_id = $4 AND step_name = $5 AND is_compensation
 	`
 
-	_, err := r.db.Exec(ctx, query, status, errorMessage, now, sagaStateID, stepName)
+	_, err := r.db.Exec(ctx, query, saga.StepStatusCompleted, now, attempts, sagaStateID, stepName)
 	return err
 }
 
 func (r *SagaRepository) GetSteps(ctx context.Context, sagaStateID string) ([]saga.SagaStep, error) {
 	query := `
-		SELECT id, saga_state_id, step_name, service_name, status, executed_at, compensated_at, error_message, created_at
+		SELECT id, saga_state_id, step_name, service_name, status, executed_at, compensated_at,
+			COALESCE(error_message, ''), attempts, payload, deadline_at, is_compensation,
+			scheduled_at, COALESCE(outbox_id::text, ''), created_at
 		FROM orchestrator_service.saga_steps
 		WHERE saga_state_id = $1
 		ORDER BY created_at ASC
@@ -196,6 +375,7 @@ func (r *SagaRepository) GetSteps(ctx context.Context, sagaStateID string) ([]sa
 	var steps []saga.SagaStep
 	for rows.Next() {
 		var step saga.SagaStep
+		var payload []byte
 		err := rows.Scan(
 			&step.ID,
 			&step.SagaStateID,
@@ -205,14 +385,289 @@ func (r *SagaRepository) GetSteps(ctx context.Context, sagaStateID string) ([]sa
 			&step.ExecutedAt,
 			&step.CompensatedAt,
 			&step.ErrorMessage,
+			&step.Attempts,
+			&payload,
+			&step.DeadlineAt,
+			&step.Compensation,
+			&step.ScheduledAt,
+			&step.OutboxID,
 			&step.CreatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		if len(payload) > 0 {
+			step.Payload = payload
+		}
+
 		steps = append(steps, step)
 	}
 
 	return steps, rows.Err()
 }
+
+// CancelScheduledSteps deletes the outbox rows and cancels the steps in one
+// statement. Rows the outbox reader has already published are gone, so those
+// steps stay as they are.
+func (r *SagaRepository) CancelScheduledSteps(ctx context.Context, sagaStateID string) ([]saga.SagaStep, error) {
+	query := `
+		WITH unsent AS (
+			DELETE FROM orchestrator_service.outbox AS o
+			USING orchestrator_service.saga_steps AS st
+			WHERE o.id = st.outbox_id AND st.saga_state_id = $1 AND st.status <> $2
+			RETURNING st.id
+		)
+		UPDATE orchestrator_service.saga_steps AS st
+		SET status = $2, executed_at = $3
+		FROM unsent
+		WHERE st.id = unsent.id
+		RETURNING st.id, st.saga_state_id, st.step_name, st.service_name, st.status, st.scheduled_at, st.outbox_id::text, st.created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, sagaStateID, saga.StepStatusCancelled, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []saga.SagaStep
+	for rows.Next() {
+		var step saga.SagaStep
+		err := rows.Scan(
+			&step.ID,
+			&step.SagaStateID,
+			&step.StepName,
+			&step.ServiceName,
+			&step.Status,
+			&step.ScheduledAt,
+			&step.OutboxID,
+			&step.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, rows.Err()
+}
+
+func (r *SagaRepository) FindExpiredSteps(ctx context.Context, now time.Time, limit int) ([]saga.ExpiredStep, error) {
+	query := `
+		SELECT st.id, st.saga_state_id, st.step_name, st.service_name, st.status, st.deadline_at, st.is_compensation, st.created_at, s.correlation_id
+		FROM orchestrator_service.saga_steps AS st
+		INNER JOIN orchestrator_service.saga_state AS s ON s.id = st.saga_state_id
+		WHERE st.status = $1 AND st.deadline_at IS NOT NULL AND st.deadline_at < $2
+			AND s.state IN ($4, $5)
+		ORDER BY st.deadline_at ASC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, saga.StepStatusPending, now, limit, saga.SagaStateStarted, saga.SagaStateCompensating)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []saga.ExpiredStep
+	for rows.Next() {
+		var step saga.ExpiredStep
+		err := rows.Scan(
+			&step.ID,
+			&step.SagaStateID,
+			&step.StepName,
+			&step.ServiceName,
+			&step.Status,
+			&step.DeadlineAt,
+			&step.Compensation,
+			&step.CreatedAt,
+			&step.CorrelationID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, rows.Err()
+}
+
+func (r *SagaRepository) StartJoin(ctx context.Context, sagaStateID, join string, branches []string) error {
+	query := `
+		UPDATE orchestrator_service.saga_state
+		SET joins = joins || jsonb_build_object($1::text, jsonb_build_object('pending', $2::jsonb, 'completed', '[]'::jsonb))
+		WHERE id = $3
+	`
+
+	pending, err := json.Marshal(branches)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, query, join, pending, sagaStateID)
+	return err
+}
+
+// CompleteJoinBranch updates the join in a single statement so concurrent
+// branch replies serialize on the row lock and see each other's changes.
+func (r *SagaRepository) CompleteJoinBranch(ctx context.Context, sagaStateID, join, branch string) (int, bool, error) {
+	query := `
+		UPDATE orchestrator_service.saga_state
+		SET joins = jsonb_set(
+			jsonb_set(joins, ARRAY[$1::text, 'pending'], (joins -> $1::text -> 'pending') - $2::text),
+			ARRAY[$1::text, 'completed'], (joins -> $1::text -> 'completed') || to_jsonb($2::text)
+		)
+		WHERE id = $3 AND joins -> $1::text -> 'pending' ? $2::text
+		RETURNING jsonb_array_length(joins -> $1::text -> 'pending')
+	`
+
+	var remaining int
+	err := r.db.QueryRow(ctx, query, join, branch, sagaStateID).Scan(&remaining)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return remaining, true, nil
+}
+
+func (r *SagaRepository) AwaitSignal(ctx context.Context, sagaStateID, name string, signal saga.SignalState) error {
+	query := `
+		UPDATE orchestrator_service.saga_state
+		SET signals = signals || jsonb_build_object($1::text, $2::jsonb)
+		WHERE id = $3
+	`
+
+	raw, err := json.Marshal(signal)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, query, name, raw, sagaStateID)
+	return err
+}
+
+// TakeSignal removes the signal in a single statement, so only one of two
+// concurrent deliveries finds it.
+func (r *SagaRepository) TakeSignal(ctx context.Context, sagaStateID, name string) (bool, error) {
+	query := `
+		UPDATE orchestrator_service.saga_state
+		SET signals = signals - $1::text
+		WHERE id = $2 AND signals ? $1::text
+	`
+
+	tag, err := r.db.Exec(ctx, query, name, sagaStateID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *SagaRepository) FindExpiredSignals(ctx context.Context, now time.Time, limit int) ([]saga.ExpiredSignal, error) {
+	query := `
+		SELECT s.id, s.correlation_id, sig.key, (sig.value ->> 'expires_at')::timestamptz AS expires_at
+		FROM orchestrator_service.saga_state AS s
+		CROSS JOIN LATERAL jsonb_each(s.signals) AS sig
+		WHERE s.state = $1 AND s.signals <> '{}'::jsonb
+			AND sig.value ->> 'expires_at' IS NOT NULL
+			AND (sig.value ->> 'expires_at')::timestamptz < $2
+		ORDER BY expires_at ASC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, saga.SagaStateStarted, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var signals []saga.ExpiredSignal
+	for rows.Next() {
+		var signal saga.ExpiredSignal
+		err := rows.Scan(
+			&signal.SagaStateID,
+			&signal.CorrelationID,
+			&signal.Name,
+			&signal.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		signals = append(signals, signal)
+	}
+
+	return signals, rows.Err()
+}
+
+func (r *SagaRepository) AppendHistory(ctx context.Context, entry saga.HistoryEntry) error {
+	query := `
+		INSERT INTO orchestrator_service.saga_events (correlation_id, kind, type, message_id, payload, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		ON CONFLICT DO NOTHING
+	`
+
+	var payload []byte
+	if entry.Payload != nil {
+		payload = entry.Payload
+	}
+
+	_, err := r.db.Exec(ctx, query,
+		entry.CorrelationID,
+		entry.Kind,
+		entry.Type,
+		entry.MessageID,
+		payload,
+		time.Now(),
+	)
+
+	return err
+}
+
+func (r *SagaRepository) GetHistory(ctx context.Context, correlationID string) ([]saga.HistoryEntry, error) {
+	query := `
+		SELECT id, correlation_id, kind, type, COALESCE(message_id, ''), payload, created_at
+		FROM orchestrator_service.saga_events
+		WHERE correlation_id = $1
+		ORDER BY id ASC
+	`
+
+	rows, err := r.db.Query(ctx, query, correlationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []saga.HistoryEntry
+	for rows.Next() {
+		var entry saga.HistoryEntry
+		var payload []byte
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.CorrelationID,
+			&entry.Kind,
+			&entry.Type,
+			&entry.MessageID,
+			&payload,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(payload) > 0 {
+			entry.Payload = payload
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
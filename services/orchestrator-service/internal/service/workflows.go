@@ -1,7 +1,7 @@
 package service
 
 import (
-	"context"
+	"embed"
 
 	"soa-video-streaming/pkg/saga"
 	"soa-video-streaming/services/orchestrator-service/domain"
@@ -9,91 +9,41 @@ import (
 	"go.uber.org/fx"
 )
 
+//go:embed workflows/*.yml
+var workflowsFS embed.FS
+
 func Module() fx.Option {
 	return fx.Options(
 		fx.Provide(
-			NewRegisterUserWorkflow,
-			NewOutboxPublisher,
+			saga.NewOutboxPublisher,
 		),
-		fx.Invoke(func(rw *RegisterUserWorkflow) {
-			rw.Register()
-		}),
+		fx.Invoke(RegisterWorkflows),
+		fx.Invoke(RegisterSchemas),
 		fx.Invoke(RunOutboxReader),
 	)
 }
 
-type RegisterUserWorkflow struct {
-	coordinator *saga.Coordinator
-}
+// hooks adds custom Go logic to declarative workflows, keyed by workflow name
+// and event.
+var hooks = map[string]map[string]saga.EventHandlerFunc{}
 
-func NewRegisterUserWorkflow(coordinator *saga.Coordinator) *RegisterUserWorkflow {
-	return &RegisterUserWorkflow{
-		coordinator: coordinator,
-	}
+func RegisterSchemas(coordinator *saga.Coordinator) {
+	coordinator.UseSchemas(domain.Schemas())
 }
 
-func (w *RegisterUserWorkflow) Register() {
-	w.coordinator.
-		RegisterStep(saga.StepDefinition{
-			Service:      "content-service",
-			Command:      domain.CmdCreateBucket,
-			Queue:        domain.QueueContentCommands,
-			SuccessEvent: domain.EventBucketCreated,
-			Compensations: []string{
-				domain.CmdCompensateUser,
-			},
-		}).
-		RegisterStep(saga.StepDefinition{
-			Service:      "notification-service",
-			Command:      domain.CmdSendEmail,
-			Queue:        domain.QueueNotificationCommands,
-			SuccessEvent: domain.EventEmailSent,
-			Compensations: []string{
-				domain.CmdCompensateBucket,
-				domain.CmdCompensateUser,
-			},
-		}).
-		RegisterCompensationQueue(domain.CmdCompensateUser, domain.QueueUserCommands).
-		RegisterCompensationQueue(domain.CmdCompensateBucket, domain.QueueContentCommands).
-		On(domain.EventUserSignUp, w.HandleUserSignUp).
-		On(domain.EventBucketCreated, w.HandleBucketCreated).
-		On(domain.EventEmailSent, w.HandleEmailSent)
-}
+func RegisterWorkflows(coordinator *saga.Coordinator) error {
+	coordinator.UseEventQueue(domain.QueueSagaEvents)
 
-func (w *RegisterUserWorkflow) HandleUserSignUp(_ context.Context, event *saga.Event) error {
-	var payload domain.UserSignUpPayload
-	if err := event.UnmarshalPayload(&payload); err != nil {
+	defs, err := saga.LoadDefinitions(workflowsFS, "workflows/*.yml")
+	if err != nil {
 		return err
 	}
 
-	if err := event.SetState(payload); err != nil {
-		return err
+	for _, def := range defs {
+		if _, err := coordinator.RegisterDefinition(def, hooks[def.Name]); err != nil {
+			return err
+		}
 	}
 
-	return event.SendCommand(domain.CmdCreateBucket, domain.BucketPayload{
-		UserID: payload.UserID,
-	})
-}
-
-func (w *RegisterUserWorkflow) HandleBucketCreated(_ context.Context, event *saga.Event) error {
-	var payload domain.BucketPayload
-	if err := event.UnmarshalPayload(&payload); err != nil {
-		return err
-	}
-
-	var state domain.UserSignUpPayload
-	if err := event.GetState(&state); err != nil {
-		return err
-	}
-
-	return event.SendCommand(domain.CmdSendEmail, domain.EmailPayload{
-		UserID:    payload.UserID,
-		Email:     state.Email,
-		FirstName: state.FirstName,
-		LastName:  state.LastName,
-	})
-}
-
-func (w *RegisterUserWorkflow) HandleEmailSent(_ context.Context, event *saga.Event) error {
-	return event.Complete()
+	return nil
 }
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/pkg/saga/sagatest"
+	"soa-video-streaming/services/orchestrator-service/domain"
+)
+
+func newRegisterUserHarness(t *testing.T) *sagatest.Harness {
+	t.Helper()
+
+	h := sagatest.New(t).UseSchemas(domain.Schemas())
+	if err := RegisterWorkflows(h.Coordinator()); err != nil {
+		t.Fatalf("register workflows: %v", err)
+	}
+
+	h.Actor(domain.QueueUserCommands).
+		Register(domain.CmdCompensateUser, nil, domain.EventUserCompensated, domain.QueueUserEvents)
+
+	return h
+}
+
+func signUp(h *sagatest.Harness, correlationID string) {
+	h.Emit(domain.EventUserSignUp, correlationID, domain.UserSignUpPayload{
+		UserID:    "user-1",
+		Email:     "jane@example.com",
+		FirstName: "Jane",
+		LastName:  "Doe",
+	})
+}
+
+func TestRegisterUserCompletes(t *testing.T) {
+	h := newRegisterUserHarness(t)
+
+	h.Actor(domain.QueueContentCommands).
+		Register(domain.CmdCreateBucket, nil, domain.EventBucketCreated, domain.QueueContentEvents)
+	h.Actor(domain.QueueNotificationCommands).
+		Register(domain.CmdSendEmail, nil, domain.EventEmailSent, domain.QueueNotificationEvents)
+
+	signUp(h, "saga-1")
+
+	h.AssertCommands(domain.CmdCreateBucket, domain.CmdSendEmail).
+		AssertStatus("saga-1", saga.SagaStateCompleted).
+		AssertStepStatus("saga-1", domain.CmdCreateBucket, saga.StepStatusCompleted).
+		AssertStepStatus("saga-1", domain.CmdSendEmail, saga.StepStatusCompleted)
+}
+
+func TestRegisterUserBucketFailureCompensatesUser(t *testing.T) {
+	h := newRegisterUserHarness(t)
+
+	h.Actor(domain.QueueContentCommands).
+		Register(domain.CmdCreateBucket, nil, domain.EventBucketCreated, domain.QueueContentEvents,
+			saga.WithFailureEvent(domain.EventBucketFailed))
+	h.FailCommand(domain.CmdCreateBucket, saga.Fail("quota_exceeded", "bucket quota exceeded"), 1)
+
+	signUp(h, "saga-1")
+
+	h.AssertCommands(domain.CmdCreateBucket, domain.CmdCompensateUser).
+		AssertStatus("saga-1", saga.SagaStateCompensated).
+		AssertStepStatus("saga-1", domain.CmdCreateBucket, saga.StepStatusFailed).
+		AssertStepStatus("saga-1", domain.CmdCompensateUser, saga.StepStatusCompleted)
+}
+
+func TestRegisterUserBucketTimeoutCompensatesUser(t *testing.T) {
+	h := newRegisterUserHarness(t)
+
+	// Nothing consumes the content commands, so create_bucket never replies
+	signUp(h, "saga-1")
+
+	h.AssertStatus("saga-1", saga.SagaStateStarted).
+		AssertStepStatus("saga-1", domain.CmdCreateBucket, saga.StepStatusPending)
+
+	h.AdvanceTime(2 * time.Minute)
+
+	h.AssertCommands(domain.CmdCreateBucket, domain.CmdCompensateUser).
+		AssertStatus("saga-1", saga.SagaStateCompensated).
+		AssertStepStatus("saga-1", domain.CmdCreateBucket, saga.StepStatusFailed).
+		AssertCommandNotSent(domain.CmdSendEmail)
+}
+
+func TestRegisteredWorkflowsAreValid(t *testing.T) {
+	coordinator := saga.NewCoordinator(nil, nil, nil)
+	if err := RegisterWorkflows(coordinator); err != nil {
+		t.Fatalf("register workflows: %v", err)
+	}
+
+	if err := coordinator.Validate(); err != nil {
+		t.Errorf("invalid workflows: %v", err)
+	}
+}
@@ -2,14 +2,13 @@ package amqptransport
 
 import (
 	"context"
+	"fmt"
 
-	"soa-video-streaming/pkg/rabbitmq"
 	"soa-video-streaming/services/orchestrator-service/domain"
 
 	"soa-video-streaming/pkg/saga"
 
 	"github.com/sirupsen/logrus"
-	gorabbit "github.com/wagslane/go-rabbitmq"
 	"go.uber.org/fx"
 )
 
@@ -20,106 +19,34 @@ func Module() fx.Option {
 }
 
 type Consumer struct {
-	Consumer *gorabbit.Consumer
-	Handler  func(d gorabbit.Delivery) gorabbit.Action
+	Queue   string
+	Handler saga.Handler
 }
 
-func GetAllConsumers(client *rabbitmq.Client, eventsController *saga.RabbitMQEventsController) ([]Consumer, error) {
-	var consumers []Consumer
-	userSignUpConsumer, err := gorabbit.NewConsumer(
-		client.Conn,
-		domain.QueueUserSignUp,
-		gorabbit.WithConsumerOptionsLogger(logrus.StandardLogger()),
-		gorabbit.WithConsumerOptionsQueueDurable,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	consumers = append(consumers, Consumer{
-		Consumer: userSignUpConsumer,
-		Handler:  eventsController.HandleEvent,
-	})
-
-	bucketConsumer, err := gorabbit.NewConsumer(
-		client.Conn,
-		domain.QueueContentEvents,
-		gorabbit.WithConsumerOptionsLogger(logrus.StandardLogger()),
-		gorabbit.WithConsumerOptionsQueueDurable,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	consumers = append(consumers, Consumer{
-		Consumer: bucketConsumer,
-		Handler:  eventsController.HandleEvent,
-	})
-
-	emailConsumer, err := gorabbit.NewConsumer(
-		client.Conn,
-		domain.QueueNotificationEvents,
-		gorabbit.WithConsumerOptionsLogger(logrus.StandardLogger()),
-		gorabbit.WithConsumerOptionsQueueDurable,
-	)
-	if err != nil {
-		return nil, err
+func GetAllConsumers(eventsController *saga.EventsController) []Consumer {
+	return []Consumer{
+		{Queue: domain.QueueUserSignUp, Handler: eventsController.HandleEvent},
+		{Queue: domain.QueueContentEvents, Handler: eventsController.HandleEvent},
+		{Queue: domain.QueueNotificationEvents, Handler: eventsController.HandleEvent},
+		{Queue: domain.QueueUserEvents, Handler: eventsController.HandleEvent},
+		{Queue: domain.QueueSagaEvents, Handler: eventsController.HandleEvent},
+		{Queue: domain.QueueSagaErrors, Handler: eventsController.HandleFailure},
 	}
-
-	consumers = append(consumers, Consumer{
-		Consumer: emailConsumer,
-		Handler:  eventsController.HandleEvent,
-	})
-
-	sagaErrorsConsumer, err := gorabbit.NewConsumer(
-		client.Conn,
-		domain.QueueSagaErrors,
-		gorabbit.WithConsumerOptionsLogger(logrus.StandardLogger()),
-		gorabbit.WithConsumerOptionsQueueDurable,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	consumers = append(consumers, Consumer{
-		Consumer: sagaErrorsConsumer,
-		Handler:  eventsController.HandleFailure,
-	})
-
-	return consumers, nil
 }
 
-func RunConsumers(lc fx.Lifecycle, eventsController *saga.RabbitMQEventsController, client *rabbitmq.Client) error {
-	var consumers []*gorabbit.Consumer
-
+// RunConsumers subscribes the orchestrator queues once the application
+// starts. The transport stops them and drains in-flight events on shutdown.
+func RunConsumers(lc fx.Lifecycle, eventsController *saga.EventsController, transport saga.Transport) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
-			allConsumers, err := GetAllConsumers(client, eventsController)
-			if err != nil {
-				return err
-			}
-
-			consumers = make([]*gorabbit.Consumer, len(allConsumers))
-			for i, consumer := range allConsumers {
-				consumers[i] = consumer.Consumer
-				go func(c Consumer) {
-					if err := c.Consumer.Run(c.Handler); err != nil {
-						logrus.WithError(err).Error("Consumer stopped")
-					}
-				}(consumer)
+			for _, consumer := range GetAllConsumers(eventsController) {
+				if err := transport.Subscribe(consumer.Queue, consumer.Handler); err != nil {
+					return fmt.Errorf("subscribe %s: %w", consumer.Queue, err)
+				}
 			}
 
 			logrus.Info("Orchestrator consumers started")
 			return nil
 		},
-		OnStop: func(ctx context.Context) error {
-			for _, consumer := range consumers {
-				consumer.Close()
-			}
-
-			return nil
-		},
 	})
-
-	return nil
 }
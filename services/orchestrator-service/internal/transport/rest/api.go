@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+
+	"soa-video-streaming/pkg/httpsrv"
+	"soa-video-streaming/pkg/middleware"
+	"soa-video-streaming/services/orchestrator-service/internal/config"
+	"soa-video-streaming/services/orchestrator-service/internal/controller/rest"
+)
+
+func Module() fx.Option {
+	return fx.Options(
+		fx.Provide(
+			rest.NewSagasController,
+			NewGinEngine,
+		),
+		fx.Invoke(RunAdminServer),
+	)
+}
+
+// NewGinEngine serves the public API. Saga administration is not part of
+// it; see NewAdminEngine.
+func NewGinEngine(
+	sagas *rest.SagasController,
+) *gin.Engine {
+	r := gin.Default()
+
+	v1 := r.Group("/api/v1")
+
+	v1.POST("/sagas/:correlation_id/signals/:signal", middleware.Auth(), sagas.Signal)
+
+	return r
+}
+
+// NewAdminEngine serves saga administration. The x-user-id header is no
+// proof of an admin, so these routes are only mounted on the internal admin
+// listener, never on the public one.
+func NewAdminEngine(
+	sagas *rest.SagasController,
+) *gin.Engine {
+	r := gin.Default()
+
+	admin := r.Group("/api/v1/admin")
+
+	adminSagas := admin.Group("/sagas")
+	{
+		adminSagas.GET("", sagas.List)
+		adminSagas.GET("/:correlation_id", sagas.Get)
+		adminSagas.GET("/:correlation_id/history", sagas.History)
+		adminSagas.GET("/:correlation_id/tree", sagas.Tree)
+		adminSagas.GET("/:correlation_id/diagram", sagas.Diagram)
+		adminSagas.POST("/:correlation_id/steps/:step/retry", sagas.RetryStep)
+		adminSagas.POST("/:correlation_id/compensate", sagas.Compensate)
+		adminSagas.POST("/:correlation_id/abort", sagas.Abort)
+	}
+
+	admin.GET("/workflows/:name/diagram", sagas.WorkflowDiagram)
+
+	return r
+}
+
+// RunAdminServer serves NewAdminEngine on admin.addr.
+func RunAdminServer(lc fx.Lifecycle, cfg *config.AppConfig, sagas *rest.SagasController) {
+	if cfg.Admin.Addr == "" {
+		logrus.Warn("Saga admin API is disabled: admin.addr is not set")
+		return
+	}
+
+	httpsrv.Serve(lc, httpsrv.NewHTTPServer(&cfg.Admin.Config, NewAdminEngine(sagas)))
+}
@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/services/orchestrator-service/internal/controller/rest"
+)
+
+func TestAdminRoutesAreOnlyOnAdminListener(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sagas := rest.NewSagasController(saga.NewCoordinator(nil, nil, nil))
+
+	public := NewGinEngine(sagas)
+	for _, path := range []string{"/api/v1/admin/sagas/order-1/abort", "/api/v1/admin/sagas/order-1/compensate"} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("x-user-id", "user-1")
+
+		rec := httptest.NewRecorder()
+		public.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("public %s: got %d, want 404", path, rec.Code)
+		}
+	}
+
+	routes := make(map[string]bool)
+	for _, route := range NewAdminEngine(sagas).Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+
+	for _, route := range []string{
+		"POST /api/v1/admin/sagas/:correlation_id/abort",
+		"POST /api/v1/admin/sagas/:correlation_id/compensate",
+		"POST /api/v1/admin/sagas/:correlation_id/steps/:step/retry",
+	} {
+		if !routes[route] {
+			t.Errorf("admin listener does not serve %s", route)
+		}
+	}
+}
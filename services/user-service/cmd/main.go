@@ -6,6 +6,7 @@ import (
 	"soa-video-streaming/pkg/httpsrv"
 	"soa-video-streaming/pkg/postgres"
 	"soa-video-streaming/pkg/rabbitmq"
+	"soa-video-streaming/pkg/saga"
 	"soa-video-streaming/services/user-service/internal/cache"
 	"soa-video-streaming/services/user-service/internal/config"
 	postgresRepos "soa-video-streaming/services/user-service/internal/repository/postgres"
@@ -25,6 +26,7 @@ func main() {
 		grpcsrv.ClientModule(),
 		httpsrv.Module(),
 		rabbitmq.Module(),
+		saga.ModuleAMQPTransport(),
 		postgres.Module(),
 		grpcTransport.Module(),
 		grpcTransport.ClientModule(),
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"soa-video-streaming/pkg/postgres"
+	"soa-video-streaming/pkg/saga"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/oagudo/outbox"
@@ -18,7 +19,7 @@ func NewOutboxRepository(db postgres.DB) *OutboxRepository {
 	}
 }
 
-func (r *OutboxRepository) WithTx(tx pgx.Tx) *OutboxRepository {
+func (r *OutboxRepository) WithTx(tx pgx.Tx) saga.OutboxRepository {
 	return &OutboxRepository{
 		db: tx,
 	}
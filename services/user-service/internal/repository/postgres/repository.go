@@ -11,6 +11,7 @@ func Module() fx.Option {
 			NewUserInfoRepository,
 			NewUserPreference,
 			NewOutboxRepository,
+			NewInboxRepository,
 			NewTransactionManager,
 		),
 	)
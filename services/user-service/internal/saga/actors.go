@@ -1,28 +1,35 @@
 package saga
 
 import (
-	"soa-video-streaming/pkg/rabbitmq"
 	"soa-video-streaming/pkg/saga"
 	"soa-video-streaming/services/orchestrator-service/domain"
+	"soa-video-streaming/services/user-service/internal/repository/postgres"
 
 	"go.uber.org/fx"
 )
 
 func RegisterUserActor(
 	lc fx.Lifecycle,
-	client *rabbitmq.Client,
+	transport saga.Transport,
 	handler *UserSagaHandler,
+	tm *postgres.TransactionManager,
+	inboxRepo *postgres.InboxRepository,
+	outboxRepo *postgres.OutboxRepository,
 ) *saga.Actor {
 	actor := saga.NewActor(
 		lc,
-		client.Conn,
-		nil, // No Outbox used in specific actor registration
+		transport,
+		outboxRepo,
 		domain.QueueUserCommands,
+		saga.WithTransactionManager(tm),
+		saga.WithInbox(inboxRepo),
+		saga.WithServiceName("user-service"),
+		saga.WithSchemas(domain.Schemas()),
 	)
 
-	actor.Register(
+	actor.RegisterTx(
 		domain.CmdCompensateUser,
-		handler.HandleCompensateUser,
+		saga.HandleTxCommand(handler.HandleCompensateUser),
 		domain.EventUserCompensated,
 		domain.QueueUserEvents,
 	)
@@ -2,13 +2,12 @@ package saga
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"soa-video-streaming/services/orchestrator-service/domain"
 
 	"soa-video-streaming/pkg/saga"
 	"soa-video-streaming/services/user-service/internal/repository/postgres"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/sirupsen/logrus"
 )
 
@@ -22,16 +21,11 @@ func NewUserSagaHandler(usersRepo *postgres.UsersRepository) *UserSagaHandler {
 	}
 }
 
-func (h *UserSagaHandler) HandleCompensateUser(ctx context.Context, msg *saga.Message) (any, error) {
-	var payload domain.CompensateUserSignUpPayload
-	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-		return nil, fmt.Errorf("unmarshal payload: %w", err)
-	}
-
-	if err := h.usersRepo.Delete(ctx, payload.UserID); err != nil {
+func (h *UserSagaHandler) HandleCompensateUser(ctx context.Context, tx pgx.Tx, payload domain.CompensateUserSignUpPayload) (saga.Empty, error) {
+	if err := h.usersRepo.WithTx(tx).Delete(ctx, payload.UserID); err != nil {
 		logrus.WithError(err).Error("Failed to delete user for compensation")
-		return nil, err
+		return saga.Empty{}, err
 	}
 
-	return nil, nil
+	return saga.Empty{}, nil
 }
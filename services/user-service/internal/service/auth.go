@@ -4,13 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
-	"soa-video-streaming/pkg/rabbitmq"
+	"fmt"
 	"soa-video-streaming/pkg/saga"
 	"soa-video-streaming/services/orchestrator-service/domain"
 	"time"
 
-	"soa-video-streaming/services/notification-service/pkg/notifications"
 	"soa-video-streaming/services/user-service/internal/config"
 	"soa-video-streaming/services/user-service/internal/domain/entity"
 	"soa-video-streaming/services/user-service/internal/repository/postgres"
@@ -19,7 +17,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/oagudo/outbox"
-	gorabbit "github.com/wagslane/go-rabbitmq"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -30,9 +28,7 @@ type AuthService struct {
 	tm           *postgres.TransactionManager
 	jwtSecretKey string
 	ttl          time.Duration
-	client       *rabbitmq.Client
-
-	sagaPub *gorabbit.Publisher
+	notifier     *Notifier
 }
 
 func NewAuthService(
@@ -41,16 +37,8 @@ func NewAuthService(
 	outboxRepo *postgres.OutboxRepository,
 	tm *postgres.TransactionManager,
 	cfg *config.AppConfig,
-	client *rabbitmq.Client,
+	notifier *Notifier,
 ) *AuthService {
-	pub, err := gorabbit.NewPublisher(
-		client.Conn,
-		gorabbit.WithPublisherOptionsLogger(nil),
-	)
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	return &AuthService{
 		usersRepo:    usersRepo,
 		userInfoRepo: userInfoRepo,
@@ -58,8 +46,7 @@ func NewAuthService(
 		tm:           tm,
 		jwtSecretKey: cfg.Auth.JwtSecretKey,
 		ttl:          cfg.Auth.JwtTTL,
-		client:       client,
-		sagaPub:      pub,
+		notifier:     notifier,
 	}
 }
 
@@ -94,34 +81,19 @@ func (a *AuthService) SignUp(ctx context.Context, isSaga bool, user entity.User)
 			return txErr
 		}
 
-		event := notifications.EventSignUp{
-			UserID:    user.Id,
-			Email:     user.Email,
-			Message:   "New user registered successfully",
-			CreatedAt: time.Now(),
-		}
-
-		eventJSON, err := json.Marshal(event)
-		if err != nil {
-			return err
+		if !isSaga {
+			return nil
 		}
 
-		msg := outbox.NewMessage(eventJSON,
-			outbox.WithID(uuid.New()),
-			outbox.WithCreatedAt(time.Now()),
-			outbox.WithMetadata([]byte(notifications.QueueSignUpEvent)),
-		)
-
-		return a.outboxRepo.WithTx(tx).Save(ctx, msg)
+		return a.startSignUpSaga(ctx, tx, user)
 	})
 	if err != nil {
 		return AuthResult{}, err
 	}
 
-	if isSaga {
-		if err = a.UserSignUpSaga(ctx, user); err != nil {
-			return AuthResult{}, err
-		}
+	// The user exists by now; a lost notification must not fail the sign-up
+	if err := a.notifier.SignUp(ctx, user); err != nil {
+		logrus.WithError(err).WithField("user_id", user.Id).Error("Failed to publish sign-up notification")
 	}
 
 	token, err := a.generateAccessToken(user)
@@ -134,7 +106,9 @@ func (a *AuthService) SignUp(ctx context.Context, isSaga bool, user entity.User)
 	}, nil
 }
 
-func (a *AuthService) UserSignUpSaga(_ context.Context, user entity.User) error {
+// startSignUpSaga writes the saga start event to the outbox, so the saga
+// starts if and only if the user is saved.
+func (a *AuthService) startSignUpSaga(ctx context.Context, tx pgx.Tx, user entity.User) error {
 	payload := domain.UserSignUpPayload{
 		UserID:    user.Id,
 		Email:     user.Email,
@@ -142,28 +116,24 @@ func (a *AuthService) UserSignUpSaga(_ context.Context, user entity.User) error
 		LastName:  user.LastName,
 	}
 
-	rawPayload, err := json.Marshal(payload)
+	msg, err := saga.NewSagaMessage("", domain.EventUserSignUp, payload,
+		saga.WithAutoCorrelationID(),
+		saga.WithSource("user-service"),
+	)
 	if err != nil {
 		return err
 	}
 
-	msg := saga.Message{
-		CorrelationID: uuid.NewString(),
-		Type:          domain.EventUserSignUp,
-		Payload:       rawPayload,
-		Timestamp:     time.Now(),
-	}
-
-	rawMsg, err := json.Marshal(msg)
+	raw, err := json.Marshal(msg)
 	if err != nil {
-		return err
+		return fmt.Errorf("marshal %s: %w", msg.Type, err)
 	}
 
-	return a.sagaPub.Publish(
-		rawMsg,
-		[]string{domain.QueueUserSignUp},
-		gorabbit.WithPublishOptionsContentType("application/json"),
-	)
+	return a.outboxRepo.WithTx(tx).Save(ctx, outbox.NewMessage(raw,
+		outbox.WithID(uuid.New()),
+		outbox.WithCreatedAt(time.Now()),
+		outbox.WithMetadata([]byte(domain.QueueUserSignUp)),
+	))
 }
 
 func (a *AuthService) SignIn(ctx context.Context, email, password string) (AuthResult, error) {
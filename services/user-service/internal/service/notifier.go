@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	gorabbit "github.com/wagslane/go-rabbitmq"
+	"go.uber.org/fx"
+
+	"soa-video-streaming/pkg/rabbitmq"
+	"soa-video-streaming/pkg/saga"
+	"soa-video-streaming/services/notification-service/pkg/notifications"
+	"soa-video-streaming/services/user-service/internal/domain/entity"
+)
+
+// Notifier publishes user notifications. They are plain JSON events, not
+// saga messages, so they go straight to RabbitMQ rather than through the
+// saga transport.
+type Notifier struct {
+	publisher *gorabbit.Publisher
+}
+
+func NewNotifier(lc fx.Lifecycle, client *rabbitmq.Client) (*Notifier, error) {
+	publisher, err := gorabbit.NewPublisher(client.Conn, gorabbit.WithPublisherOptionsLogger(logrus.StandardLogger()))
+	if err != nil {
+		return nil, fmt.Errorf("create notification publisher: %w", err)
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(_ context.Context) error {
+			publisher.Close()
+			return nil
+		},
+	})
+
+	return &Notifier{publisher: publisher}, nil
+}
+
+func (n *Notifier) SignUp(ctx context.Context, user entity.User) error {
+	body, err := json.Marshal(notifications.EventSignUp{
+		UserID:    user.Id,
+		Email:     user.Email,
+		Message:   "New user registered successfully",
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal sign-up event: %w", err)
+	}
+
+	return n.publisher.PublishWithContext(ctx, body, []string{notifications.QueueSignUpEvent},
+		gorabbit.WithPublishOptionsContentType(saga.ContentTypeJSON),
+		gorabbit.WithPublishOptionsPersistentDelivery,
+	)
+}
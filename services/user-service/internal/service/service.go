@@ -1,6 +1,7 @@
 package service
 
 import (
+	"soa-video-streaming/pkg/saga"
 	usersaga "soa-video-streaming/services/user-service/internal/saga"
 
 	"go.uber.org/fx"
@@ -11,7 +12,8 @@ func Module() fx.Option {
 		fx.Provide(
 			NewAuthService,
 			NewUsersService,
-			NewOutboxPublisher,
+			NewNotifier,
+			saga.NewOutboxPublisher,
 			usersaga.NewUserSagaHandler,
 		),
 		fx.Invoke(RunOutboxReader),